@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 	"voice-training-app/internal/api"
+	"voice-training-app/internal/audio"
 	"voice-training-app/internal/database"
+	"voice-training-app/internal/jobs"
 	"voice-training-app/internal/middleware"
+	"voice-training-app/internal/storage"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -24,6 +34,33 @@ func main() {
 	}
 	defer database.Close()
 
+	// Configure blob storage for uploads and processed audio
+	if err := storage.Init(); err != nil {
+		log.Fatal("Failed to configure storage:", err)
+	}
+
+	// Stop on Ctrl+C or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Pick audio decoding backends
+	log.Printf("Audio decoders: %s", strings.Join(audio.DetectDecoders(), ", "))
+
+	// Start background job workers
+	api.RegisterJobs()
+	pool := jobs.NewPool(jobs.ConfigFromEnv())
+	pool.Start(ctx)
+
+	// Re-queue recordings whose processing was lost before a restart
+	if n, err := api.RequeueUnprocessedRecordings(ctx); err != nil {
+		log.Println("Failed to re-queue unprocessed recordings:", err)
+	} else if n > 0 {
+		log.Printf("Re-queued %d unprocessed recordings", n)
+	}
+
+	// Remove resumable uploads that were abandoned
+	go api.ReapExpiredUploads(ctx, 15*time.Minute)
+
 	// Create Gin router
 	router := gin.Default()
 
@@ -40,11 +77,13 @@ func main() {
 		allowedOrigins = append(allowedOrigins, frontendURL)
 	}
 
+	api.AllowedOrigins = allowedOrigins
+
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Range", "If-None-Match", "If-Modified-Since", "Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata", "Upload-Checksum"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Location", "Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Expires"},
 		AllowCredentials: true,
 	}))
 
@@ -57,16 +96,65 @@ func main() {
 			auth.POST("/login", api.Login)
 			auth.POST("/logout", api.Logout)
 			auth.GET("/me", middleware.AuthRequired(), api.Me)
+			auth.PATCH("/me", middleware.AuthRequired(), api.UpdateMe)
+		}
+
+		me := v1.Group("/me")
+		me.Use(middleware.AuthRequired())
+		{
+			me.GET("/achievements", api.ListAchievements)
+			me.GET("/progress", api.GetProgress)
+			me.GET("/voice-profile", api.GetVoiceProfile)
+			me.PATCH("/voice-profile", api.UpdateVoiceProfile)
+			me.POST("/voice-profile/baseline", api.MeasureVoiceBaseline)
 		}
 
+		// Live pitch feedback over WebSocket
+		v1.GET("/live/pitch", middleware.AuthRequired(), api.LivePitch)
+
 		recordings := v1.Group("/recordings")
 		recordings.Use(middleware.AuthRequired())
 		{
 			recordings.POST("/upload", api.UploadRecording)
+			recordings.POST("/uploads", api.CreateResumableUpload)
+			recordings.HEAD("/uploads/:id", api.GetResumableUploadStatus)
+			recordings.PATCH("/uploads/:id", api.PatchResumableUpload)
+			recordings.DELETE("/uploads/:id", api.DeleteResumableUpload)
 			recordings.GET("", api.ListRecordings)
+			recordings.GET("/tags", api.ListRecordingTags)
 			recordings.GET("/:id", api.GetRecording)
+			recordings.PATCH("/:id", api.UpdateRecording)
+			recordings.GET("/:id/pitch", api.GetPitchContour)
+			recordings.GET("/:id/waveform", api.GetWaveform)
+			recordings.GET("/:id/spectrogram", api.GetSpectrogram)
+			recordings.GET("/:id/audio", api.StreamRecordingAudio)
 			recordings.DELETE("/:id", api.DeleteRecording)
 		}
+
+		exercises := v1.Group("/exercises")
+		exercises.Use(middleware.AuthRequired())
+		{
+			exercises.GET("", api.ListExercises)
+			exercises.GET("/categories", api.ListExerciseCategories)
+			exercises.GET("/:id", api.GetExercise)
+			exercises.POST("", middleware.AdminRequired(), api.CreateExercise)
+			exercises.PUT("/:id", middleware.AdminRequired(), api.UpdateExercise)
+			exercises.DELETE("/:id", middleware.AdminRequired(), api.DeleteExercise)
+			exercises.POST("/categories", middleware.AdminRequired(), api.CreateExerciseCategory)
+			exercises.PUT("/categories/:id", middleware.AdminRequired(), api.UpdateExerciseCategory)
+			exercises.DELETE("/categories/:id", middleware.AdminRequired(), api.DeleteExerciseCategory)
+		}
+
+		sessions := v1.Group("/sessions")
+		sessions.Use(middleware.AuthRequired())
+		{
+			sessions.POST("", api.StartSession)
+			sessions.GET("", api.ListSessions)
+			sessions.GET("/:id", api.GetSession)
+			sessions.POST("/:id/heartbeat", api.SessionHeartbeat)
+			sessions.POST("/:id/recordings", api.AttachSessionRecording)
+			sessions.POST("/:id/complete", api.CompleteSession)
+		}
 	}
 
 	// Health check
@@ -80,8 +168,27 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Server starting on port %s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: router,
 	}
+
+	go func() {
+		log.Printf("Server starting on port %s", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Server shutdown failed:", err)
+	}
+
+	// Wait for in-flight jobs before closing the database
+	pool.Stop()
 }
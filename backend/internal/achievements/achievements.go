@@ -0,0 +1,162 @@
+package achievements
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Metrics an achievement can be measured by
+const (
+	MetricRecordings      = "recordings" // Processed recordings; failed and pending uploads do not count
+	MetricSessions        = "sessions_completed"
+	MetricPracticeMinutes = "practice_minutes"
+	MetricStreak          = "streak_days"
+	MetricLevel           = "level"
+	MetricTargetHits      = "target_pitch_hits" // Processed recordings with median pitch in the user's target band
+)
+
+// Achievement unlocks once the user's value for Metric reaches Goal
+type Achievement struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Metric      string `json:"metric"`
+	Goal        int    `json:"goal"`
+}
+
+// Catalog lists every achievement. IDs are stored with unlocks, so they must
+// never change; retired achievements should stay listed.
+var Catalog = []Achievement{
+	{ID: "first_recording", Name: "First Words", Description: "Make your first recording", Metric: MetricRecordings, Goal: 1},
+	{ID: "recordings_10", Name: "Warming Up", Description: "Make 10 recordings", Metric: MetricRecordings, Goal: 10},
+	{ID: "recordings_100", Name: "Centurion", Description: "Make 100 recordings", Metric: MetricRecordings, Goal: 100},
+	{ID: "first_session", Name: "Showing Up", Description: "Complete a practice session", Metric: MetricSessions, Goal: 1},
+	{ID: "sessions_25", Name: "Regular", Description: "Complete 25 practice sessions", Metric: MetricSessions, Goal: 25},
+	{ID: "practice_60_minutes", Name: "Hour of Practice", Description: "Practice for 60 minutes in total", Metric: MetricPracticeMinutes, Goal: 60},
+	{ID: "practice_600_minutes", Name: "Dedicated", Description: "Practice for 10 hours in total", Metric: MetricPracticeMinutes, Goal: 600},
+	{ID: "streak_3", Name: "Three in a Row", Description: "Practice 3 days in a row", Metric: MetricStreak, Goal: 3},
+	{ID: "streak_7", Name: "Week Streak", Description: "Practice 7 days in a row", Metric: MetricStreak, Goal: 7},
+	{ID: "streak_30", Name: "Month Streak", Description: "Practice 30 days in a row", Metric: MetricStreak, Goal: 30},
+	{ID: "level_5", Name: "Rising Voice", Description: "Reach level 5", Metric: MetricLevel, Goal: 5},
+	{ID: "level_10", Name: "Seasoned Voice", Description: "Reach level 10", Metric: MetricLevel, Goal: 10},
+	{ID: "target_pitch_10", Name: "On Target", Description: "Hit your target pitch range in 10 recordings", Metric: MetricTargetHits, Goal: 10},
+}
+
+// DB is satisfied by both the connection pool and a transaction
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Stats holds a user's value for each metric
+type Stats map[string]int
+
+// LoadStats measures every metric for a user
+func LoadStats(ctx context.Context, db DB, userID string) (Stats, error) {
+	var recordings, sessions, minutes, streak, level, targetHits int
+	err := db.QueryRow(ctx,
+		`SELECT
+		   (SELECT COUNT(*) FROM recordings WHERE user_id = u.id AND status = 'done'),
+		   (SELECT COUNT(*) FROM sessions WHERE user_id = u.id AND status = 'completed'),
+		   (SELECT COALESCE(SUM(duration), 0) / 60 FROM sessions WHERE user_id = u.id AND status = 'completed'),
+		   COALESCE(u.streak_count, 0),
+		   COALESCE(u.level, 1),
+		   (SELECT COUNT(*) FROM recordings r
+		    WHERE r.user_id = u.id AND r.status = 'done'
+		      AND r.pitch_hz BETWEEN p.target_pitch_min_hz AND p.target_pitch_max_hz)
+		 FROM users u LEFT JOIN voice_profiles p ON p.user_id = u.id
+		 WHERE u.id = $1`,
+		userID).Scan(&recordings, &sessions, &minutes, &streak, &level, &targetHits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load achievement stats: %w", err)
+	}
+
+	return Stats{
+		MetricRecordings:      recordings,
+		MetricSessions:        sessions,
+		MetricPracticeMinutes: minutes,
+		MetricStreak:          streak,
+		MetricLevel:           level,
+		MetricTargetHits:      targetHits,
+	}, nil
+}
+
+// Evaluate unlocks every achievement whose goal the user has reached and
+// returns the ones unlocked by this call. Unlocks are never revoked, so a
+// lapsed streak keeps its badges.
+func Evaluate(ctx context.Context, db DB, userID string) ([]Achievement, error) {
+	stats, err := LoadStats(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	unlocked := []Achievement{}
+	for _, a := range Catalog {
+		if stats[a.Metric] < a.Goal {
+			continue
+		}
+
+		tag, err := db.Exec(ctx,
+			`INSERT INTO user_achievements (user_id, achievement_id)
+			 VALUES ($1, $2)
+			 ON CONFLICT (user_id, achievement_id) DO NOTHING`,
+			userID, a.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unlock achievement %s: %w", a.ID, err)
+		}
+		if tag.RowsAffected() > 0 {
+			unlocked = append(unlocked, a)
+		}
+	}
+
+	return unlocked, nil
+}
+
+// Status is an achievement with a user's progress toward it
+type Status struct {
+	Achievement
+	Progress   int        `json:"progress"` // Current value, capped at Goal
+	Unlocked   bool       `json:"unlocked"`
+	UnlockedAt *time.Time `json:"unlocked_at,omitempty"`
+}
+
+// List returns the whole catalog with the user's progress and unlocks
+func List(ctx context.Context, db DB, userID string) ([]Status, error) {
+	stats, err := LoadStats(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx,
+		`SELECT achievement_id, unlocked_at FROM user_achievements WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load unlocked achievements: %w", err)
+	}
+	unlockedAt := map[string]time.Time{}
+	var id string
+	var at time.Time
+	_, err = pgx.ForEachRow(rows, []any{&id, &at}, func() error {
+		unlockedAt[id] = at
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load unlocked achievements: %w", err)
+	}
+
+	statuses := make([]Status, len(Catalog))
+	for i, a := range Catalog {
+		statuses[i] = Status{Achievement: a, Progress: min(stats[a.Metric], a.Goal)}
+		if at, ok := unlockedAt[a.ID]; ok {
+			statuses[i].Unlocked = true
+			statuses[i].UnlockedAt = &at
+			statuses[i].Progress = a.Goal
+		}
+	}
+
+	return statuses, nil
+}
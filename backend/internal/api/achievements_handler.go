@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"voice-training-app/internal/achievements"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAchievements returns the achievement catalog with the authenticated
+// user's progress toward each achievement and when it was unlocked
+func ListAchievements(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	statuses, err := achievements.List(context.Background(), database.DB, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch achievements",
+		})
+		return
+	}
+
+	unlocked := 0
+	for _, s := range statuses {
+		if s.Unlocked {
+			unlocked++
+		}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"achievements": statuses,
+			"unlocked":     unlocked,
+			"total":        len(statuses),
+		},
+	})
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"voice-training-app/internal/audio"
+	"voice-training-app/internal/models"
+	"voice-training-app/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AudioVariantOriginal  = "original"
+	AudioVariantProcessed = "processed"
+)
+
+// audioContentTypes maps upload extensions to their MIME types; Go's built-in
+// table does not cover most audio containers
+var audioContentTypes = map[string]string{
+	".webm": "audio/webm",
+	".weba": "audio/webm",
+	".mp4":  "audio/mp4",
+	".m4a":  "audio/mp4",
+	".wav":  "audio/wav",
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+}
+
+// StreamRecordingAudio serves the audio of a recording with support for
+// byte ranges and conditional requests. ?variant=original (default) serves
+// the upload, ?variant=processed serves the transcoded WAV.
+func StreamRecordingAudio(c *gin.Context) {
+	variant := c.DefaultQuery("variant", AudioVariantOriginal)
+	if variant != AudioVariantOriginal && variant != AudioVariantProcessed {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "variant must be original or processed",
+		})
+		return
+	}
+
+	var (
+		recording *models.Recording
+		ok        bool
+		key       string
+	)
+	if variant == AudioVariantProcessed {
+		recording, ok = loadProcessedRecording(c)
+		if !ok {
+			return
+		}
+		key = audio.WAVKeyFor(recording.FilePath)
+	} else {
+		recording, ok = loadOwnedRecording(c)
+		if !ok {
+			return
+		}
+		key = recording.FilePath
+	}
+
+	obj, err := storage.Default.Get(c.Request.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Audio file not available",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to read audio file",
+		})
+		return
+	}
+	defer obj.Close()
+	info := obj.Info()
+
+	contentType, known := audioContentTypes[strings.ToLower(path.Ext(key))]
+	if !known {
+		contentType = "application/octet-stream"
+	}
+
+	// Headers must be set before ServeContent, which evaluates If-None-Match,
+	// If-Modified-Since and Range against them
+	c.Header("Content-Type", contentType)
+	c.Header("ETag", fmt.Sprintf(`"%s-%s-%x-%x"`, recording.ID, variant, info.ModTime.UnixNano(), info.Size))
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Header("Accept-Ranges", "bytes")
+
+	http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime, obj)
+}
@@ -3,8 +3,10 @@ package api
 import (
 	"context"
 	"net/http"
+	"time"
 	"voice-training-app/internal/auth"
 	"voice-training-app/internal/database"
+	"voice-training-app/internal/gamification"
 	"voice-training-app/internal/models"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +23,17 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if !gamification.ValidTimezone(req.Timezone) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid timezone",
+		})
+		return
+	}
+
 	// Check if user exists
 	var exists bool
 	err := database.DB.QueryRow(context.Background(),
@@ -54,12 +67,12 @@ func Register(c *gin.Context) {
 	// Create user
 	var user models.User
 	err = database.DB.QueryRow(context.Background(),
-		`INSERT INTO users (email, password_hash)
-		 VALUES ($1, $2)
-		 RETURNING id, email, created_at, updated_at, streak_count, total_xp, level`,
-		req.Email, string(hashedPassword)).Scan(
+		`INSERT INTO users (email, password_hash, timezone)
+		 VALUES ($1, $2, $3)
+		 RETURNING id, email, created_at, updated_at, streak_count, total_xp, level, streak_freezes, timezone`,
+		req.Email, string(hashedPassword), req.Timezone).Scan(
 		&user.ID, &user.Email, &user.CreatedAt, &user.UpdatedAt,
-		&user.StreakCount, &user.TotalXP, &user.Level)
+		&user.StreakCount, &user.TotalXP, &user.Level, &user.StreakFreezes, &user.Timezone)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
@@ -144,13 +157,7 @@ func Me(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	err := database.DB.QueryRow(context.Background(),
-		`SELECT id, email, created_at, updated_at, streak_count, total_xp, level
-		 FROM users WHERE id = $1`, userID).Scan(
-		&user.ID, &user.Email, &user.CreatedAt, &user.UpdatedAt,
-		&user.StreakCount, &user.TotalXP, &user.Level)
-
+	user, err := loadUser(context.Background(), userID.(string))
 	if err != nil {
 		c.JSON(http.StatusNotFound, models.APIResponse{
 			Success: false,
@@ -162,11 +169,90 @@ func Me(c *gin.Context) {
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Data: gin.H{
-			"user": user,
+			"user":     user,
+			"progress": gamification.ProgressFor(user.TotalXP),
 		},
 	})
 }
 
+// UpdateMe changes the timezone of the authenticated user
+func UpdateMe(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "User not authenticated",
+		})
+		return
+	}
+
+	var req models.UpdateMeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if req.TargetPitchMinHz != nil || req.TargetPitchMaxHz != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "The target pitch range is now part of the voice profile; set it with PATCH /api/v1/me/voice-profile",
+			Code:    "moved_to_voice_profile",
+		})
+		return
+	}
+
+	if req.Timezone != nil && !gamification.ValidTimezone(*req.Timezone) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid timezone",
+		})
+		return
+	}
+
+	// Unset fields keep their current value
+	_, err := database.DB.Exec(context.Background(),
+		`UPDATE users SET timezone = COALESCE($1, timezone), updated_at = NOW() WHERE id = $2`,
+		req.Timezone, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update user",
+		})
+		return
+	}
+
+	Me(c)
+}
+
+// loadUser loads a user with the streak as of today in their timezone; a
+// streak with more missed days than freeze tokens reads as 0
+func loadUser(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	var lastPractice *time.Time
+	err := database.DB.QueryRow(ctx,
+		`SELECT id, email, created_at, updated_at, streak_count, last_practice_date, total_xp, level,
+		        streak_freezes, timezone
+		 FROM users WHERE id = $1`, userID).Scan(
+		&user.ID, &user.Email, &user.CreatedAt, &user.UpdatedAt,
+		&user.StreakCount, &lastPractice, &user.TotalXP, &user.Level,
+		&user.StreakFreezes, &user.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	streak := gamification.Streak{Count: user.StreakCount, LastDay: lastPractice, Freezes: user.StreakFreezes}
+	user.StreakCount = streak.Current(gamification.Day(time.Now(), gamification.LoadLocation(user.Timezone)))
+	if lastPractice != nil {
+		date := lastPractice.Format(time.DateOnly)
+		user.LastPracticeDate = &date
+	}
+
+	return &user, nil
+}
+
 func Logout(c *gin.Context) {
 	c.SetCookie("token", "", -1, "/", "", false, true)
 	c.JSON(http.StatusOK, models.APIResponse{
@@ -0,0 +1,540 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"voice-training-app/internal/audio"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Postgres error codes mapped to client errors
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+func pgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// exerciseColumns lists the exercises columns read by scanExercise, in scan
+// order. Queries must alias exercises as e and join exercise_categories as c.
+const exerciseColumns = `e.id, e.category_id, c.slug, e.slug, e.title, e.description, e.instructions,
+	e.kind, e.difficulty, e.target_pitch_min_hz, e.target_pitch_max_hz, e.target_duration_sec, e.repetitions,
+	e.created_at, e.updated_at`
+
+// scanExercise scans a row selected with exerciseColumns into e
+func scanExercise(e *models.Exercise, row pgx.Row) error {
+	return row.Scan(&e.ID, &e.CategoryID, &e.CategorySlug, &e.Slug, &e.Title, &e.Description, &e.Instructions,
+		&e.Kind, &e.Difficulty, &e.TargetPitchMinHz, &e.TargetPitchMaxHz, &e.TargetDurationSec, &e.Repetitions,
+		&e.CreatedAt, &e.UpdatedAt)
+}
+
+// categoryColumns lists the exercise_categories columns read by scanCategory, in scan order
+const categoryColumns = `id, slug, name, description, sort_order, created_at, updated_at`
+
+// scanCategory scans a row selected with categoryColumns into cat
+func scanCategory(cat *models.ExerciseCategory, row pgx.Row) error {
+	return row.Scan(&cat.ID, &cat.Slug, &cat.Name, &cat.Description, &cat.SortOrder, &cat.CreatedAt, &cat.UpdatedAt)
+}
+
+// ListExerciseCategories returns every exercise category in display order
+func ListExerciseCategories(c *gin.Context) {
+	rows, err := database.DB.Query(context.Background(),
+		`SELECT `+categoryColumns+` FROM exercise_categories ORDER BY sort_order, name`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch categories",
+		})
+		return
+	}
+
+	categories, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.ExerciseCategory, error) {
+		var cat models.ExerciseCategory
+		err := scanCategory(&cat, row)
+		return cat, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch categories",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"categories": categories,
+		},
+	})
+}
+
+// ListExercises returns the exercise library, optionally filtered by
+// ?category= (slug), ?kind=, ?difficulty= or ?max_difficulty= and ?q= (title search)
+func ListExercises(c *gin.Context) {
+	var category, kind, search *string
+	if raw := c.Query("category"); raw != "" {
+		category = &raw
+	}
+	if raw := c.Query("kind"); raw != "" {
+		if !models.ValidExerciseKind(raw) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid kind filter",
+			})
+			return
+		}
+		kind = &raw
+	}
+	if raw := c.Query("q"); raw != "" {
+		search = &raw
+	}
+
+	var difficulty, maxDifficulty *int
+	for _, f := range []struct {
+		name string
+		dst  **int
+	}{{"difficulty", &difficulty}, {"max_difficulty", &maxDifficulty}} {
+		raw := c.Query(f.name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > 5 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   f.name + " must be between 1 and 5",
+			})
+			return
+		}
+		*f.dst = &v
+	}
+
+	rows, err := database.DB.Query(context.Background(),
+		`SELECT `+exerciseColumns+`
+		 FROM exercises e JOIN exercise_categories c ON c.id = e.category_id
+		 WHERE ($1::text IS NULL OR c.slug = $1)
+		   AND ($2::text IS NULL OR e.kind = $2)
+		   AND ($3::int IS NULL OR e.difficulty = $3)
+		   AND ($4::int IS NULL OR e.difficulty <= $4)
+		   AND ($5::text IS NULL OR strpos(lower(e.title), lower($5)) > 0)
+		 ORDER BY c.sort_order, e.difficulty, e.title`,
+		category, kind, difficulty, maxDifficulty, search)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch exercises",
+		})
+		return
+	}
+
+	exercises, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Exercise, error) {
+		var e models.Exercise
+		err := scanExercise(&e, row)
+		return e, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch exercises",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"exercises": exercises,
+		},
+	})
+}
+
+// GetExercise returns a single exercise by ID
+func GetExercise(c *gin.Context) {
+	exercise, err := loadExercise(context.Background(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Exercise not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"exercise": exercise,
+		},
+	})
+}
+
+// CreateExercise adds an exercise to the library (admin only)
+func CreateExercise(c *gin.Context) {
+	req, ok := bindExerciseRequest(c)
+	if !ok {
+		return
+	}
+
+	var id string
+	err := database.DB.QueryRow(context.Background(),
+		`INSERT INTO exercises (category_id, slug, title, description, instructions, kind, difficulty,
+		                        target_pitch_min_hz, target_pitch_max_hz, target_duration_sec, repetitions)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING id`,
+		req.CategoryID, req.Slug, req.Title, req.Description, req.Instructions, req.Kind, req.Difficulty,
+		req.TargetPitchMinHz, req.TargetPitchMaxHz, req.TargetDurationSec, req.Repetitions).Scan(&id)
+	if respondExerciseWriteError(c, err) {
+		return
+	}
+
+	respondExercise(c, http.StatusCreated, id)
+}
+
+// UpdateExercise replaces the definition of an exercise (admin only)
+func UpdateExercise(c *gin.Context) {
+	req, ok := bindExerciseRequest(c)
+	if !ok {
+		return
+	}
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Exercise not found",
+		})
+		return
+	}
+
+	var id string
+	err := database.DB.QueryRow(context.Background(),
+		`UPDATE exercises
+		 SET category_id = $1, slug = $2, title = $3, description = $4, instructions = $5, kind = $6,
+		     difficulty = $7, target_pitch_min_hz = $8, target_pitch_max_hz = $9, target_duration_sec = $10,
+		     repetitions = $11, updated_at = NOW()
+		 WHERE id = $12
+		 RETURNING id`,
+		req.CategoryID, req.Slug, req.Title, req.Description, req.Instructions, req.Kind, req.Difficulty,
+		req.TargetPitchMinHz, req.TargetPitchMaxHz, req.TargetDurationSec, req.Repetitions,
+		c.Param("id")).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Exercise not found",
+		})
+		return
+	}
+	if respondExerciseWriteError(c, err) {
+		return
+	}
+
+	respondExercise(c, http.StatusOK, id)
+}
+
+// DeleteExercise removes an exercise (admin only). Recordings made for it
+// are kept and lose the link.
+func DeleteExercise(c *gin.Context) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Exercise not found",
+		})
+		return
+	}
+
+	tag, err := database.DB.Exec(context.Background(),
+		`DELETE FROM exercises WHERE id = $1`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete exercise",
+		})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Exercise not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    nil,
+	})
+}
+
+// CreateExerciseCategory adds a category (admin only)
+func CreateExerciseCategory(c *gin.Context) {
+	var req models.CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	var category models.ExerciseCategory
+	err := scanCategory(&category, database.DB.QueryRow(context.Background(),
+		`INSERT INTO exercise_categories (slug, name, description, sort_order)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING `+categoryColumns,
+		req.Slug, req.Name, req.Description, req.SortOrder))
+	if pgErrorCode(err) == pgUniqueViolation {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "A category with this slug already exists",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create category",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"category": category,
+		},
+	})
+}
+
+// UpdateExerciseCategory replaces a category (admin only)
+func UpdateExerciseCategory(c *gin.Context) {
+	var req models.CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request: " + err.Error(),
+		})
+		return
+	}
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Category not found",
+		})
+		return
+	}
+
+	var category models.ExerciseCategory
+	err := scanCategory(&category, database.DB.QueryRow(context.Background(),
+		`UPDATE exercise_categories
+		 SET slug = $1, name = $2, description = $3, sort_order = $4, updated_at = NOW()
+		 WHERE id = $5
+		 RETURNING `+categoryColumns,
+		req.Slug, req.Name, req.Description, req.SortOrder, c.Param("id")))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Category not found",
+		})
+		return
+	}
+	if pgErrorCode(err) == pgUniqueViolation {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "A category with this slug already exists",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update category",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"category": category,
+		},
+	})
+}
+
+// DeleteExerciseCategory removes an empty category (admin only)
+func DeleteExerciseCategory(c *gin.Context) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Category not found",
+		})
+		return
+	}
+
+	tag, err := database.DB.Exec(context.Background(),
+		`DELETE FROM exercise_categories WHERE id = $1`, c.Param("id"))
+	if pgErrorCode(err) == pgForeignKeyViolation {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "Category still has exercises",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete category",
+		})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Category not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    nil,
+	})
+}
+
+// bindExerciseRequest parses and checks an exercise definition. It writes the
+// error response itself and returns false when the handler should stop.
+func bindExerciseRequest(c *gin.Context) (*models.ExerciseRequest, bool) {
+	var req models.ExerciseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	invalid := ""
+	switch {
+	case !models.ValidExerciseKind(req.Kind):
+		invalid = "kind must be sustain, glide, speech or breathing"
+	case (req.TargetPitchMinHz == nil) != (req.TargetPitchMaxHz == nil):
+		invalid = "target_pitch_min_hz and target_pitch_max_hz must be set together"
+	case req.TargetPitchMinHz != nil && (*req.TargetPitchMinHz < audio.MinPitchHz ||
+		*req.TargetPitchMaxHz > audio.MaxPitchHz || *req.TargetPitchMinHz >= *req.TargetPitchMaxHz):
+		invalid = fmt.Sprintf("Target pitch range must lie within %.0f-%.0f Hz", audio.MinPitchHz, audio.MaxPitchHz)
+	}
+	if invalid != "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   invalid,
+		})
+		return nil, false
+	}
+
+	if req.Repetitions == 0 {
+		req.Repetitions = 1
+	}
+	return &req, true
+}
+
+// respondExerciseWriteError writes the response for a failed exercise insert
+// or update and reports whether there was an error
+func respondExerciseWriteError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case pgErrorCode(err) == pgUniqueViolation:
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "An exercise with this slug already exists",
+		})
+	case pgErrorCode(err) == pgForeignKeyViolation:
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Category not found",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to save exercise",
+		})
+	}
+	return true
+}
+
+func respondExercise(c *gin.Context, status int, id string) {
+	exercise, err := loadExercise(context.Background(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch exercise",
+		})
+		return
+	}
+
+	c.JSON(status, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"exercise": exercise,
+		},
+	})
+}
+
+func loadExercise(ctx context.Context, exerciseID string) (*models.Exercise, error) {
+	if _, err := uuid.Parse(exerciseID); err != nil {
+		return nil, pgx.ErrNoRows
+	}
+
+	var exercise models.Exercise
+	err := scanExercise(&exercise, database.DB.QueryRow(ctx,
+		`SELECT `+exerciseColumns+`
+		 FROM exercises e JOIN exercise_categories c ON c.id = e.category_id
+		 WHERE e.id = $1`,
+		exerciseID))
+	if err != nil {
+		return nil, err
+	}
+	return &exercise, nil
+}
+
+// checkUploadExercise checks that exerciseID names an exercise an upload can
+// be linked to. Failures are returned as *ingestError.
+func checkUploadExercise(ctx context.Context, exerciseID string) error {
+	if _, err := uuid.Parse(exerciseID); err != nil {
+		return &ingestError{status: http.StatusBadRequest, message: "Invalid exercise_id"}
+	}
+
+	_, err := loadExercise(ctx, exerciseID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &ingestError{status: http.StatusNotFound, message: "Exercise not found"}
+	}
+	if err != nil {
+		return &ingestError{status: http.StatusInternalServerError, message: "Failed to load exercise", err: err}
+	}
+	return nil
+}
+
+// linkToExercise links a recording to the exercise it was made for
+func linkToExercise(ctx context.Context, tx pgx.Tx, exerciseID string, recording *models.Recording) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE recordings SET exercise_id = $1 WHERE id = $2`,
+		exerciseID, recording.ID)
+	if err != nil {
+		return err
+	}
+
+	recording.ExerciseID = &exerciseID
+	return nil
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"time"
+	"voice-training-app/internal/achievements"
+	"voice-training-app/internal/audio"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/models"
+	"voice-training-app/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ingestError is a failed ingest together with the response it maps to
+type ingestError struct {
+	status  int
+	message string
+	code    string
+	err     error
+}
+
+func (e *ingestError) Error() string {
+	if e.err != nil {
+		return e.message + ": " + e.err.Error()
+	}
+	return e.message
+}
+
+func (e *ingestError) Unwrap() error { return e.err }
+
+// ingestLinker runs inside the transaction that creates a recording, so
+// callers can tie their own rows to it atomically
+type ingestLinker func(ctx context.Context, tx pgx.Tx, recording *models.Recording) error
+
+// linkAll combines linkers into one that runs them in order, skipping nil ones
+func linkAll(links ...ingestLinker) ingestLinker {
+	return func(ctx context.Context, tx pgx.Tx, recording *models.Recording) error {
+		for _, link := range links {
+			if link == nil {
+				continue
+			}
+			if err := link(ctx, tx, recording); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ingestRecording validates the uploaded file at localPath, stores it and
+// creates its recording with processing queued. filename and contentType are
+// what the client declared. Failures are returned as *ingestError.
+func ingestRecording(ctx context.Context, userID, localPath, filename, contentType string, link ingestLinker) (*models.Recording, error) {
+	// Check the content really is audio we can process, whatever the client claims
+	format, _, err := audio.ValidateUpload(ctx, localPath, filename, contentType)
+	var validationErr *audio.ValidationError
+	if errors.As(err, &validationErr) {
+		return nil, &ingestError{status: http.StatusUnsupportedMediaType, message: validationErr.Message, code: validationErr.Code}
+	}
+	if err != nil {
+		return nil, &ingestError{status: http.StatusInternalServerError, message: "Failed to read file", err: err}
+	}
+
+	// Reject recordings outside the configured length when the container reports it.
+	// Files ffprobe cannot read are checked again after decoding.
+	duration := 0.0
+	if probed, err := audio.ProbeDuration(ctx, localPath); err == nil {
+		if err := audio.DurationLimitsFromEnv().Check(probed); err != nil {
+			return nil, &ingestError{status: http.StatusUnprocessableEntity, message: err.Error()}
+		}
+		duration = probed
+	}
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, &ingestError{status: http.StatusInternalServerError, message: "Failed to read file", err: err}
+	}
+
+	// Generate unique filename with the extension of the sniffed format
+	newFilename := fmt.Sprintf("%s-%d%s", uuid.New().String(), time.Now().Unix(), format.Extensions[0])
+	fileKey := path.Join(UploadPrefix, newFilename)
+
+	// Save file to storage
+	if err := storage.PutFile(ctx, storage.Default, fileKey, localPath, format.ContentType); err != nil {
+		return nil, &ingestError{status: http.StatusInternalServerError, message: "Failed to save file", err: err}
+	}
+
+	recording, err := createRecording(ctx, userID, fileKey, filename, duration, info.Size(), link)
+	if err != nil {
+		storage.Default.Delete(ctx, fileKey) // Clean up on error
+		return nil, err
+	}
+
+	return recording, nil
+}
+
+// createRecording saves recording metadata and queues its analysis in one transaction
+func createRecording(ctx context.Context, userID, fileKey, filename string, duration float64, size int64, link ingestLinker) (*models.Recording, error) {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, &ingestError{status: http.StatusInternalServerError, message: "Failed to save recording metadata", err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	var recording models.Recording
+	err = scanRecording(&recording, tx.QueryRow(ctx,
+		`INSERT INTO recordings (user_id, file_path, original_filename, duration, file_size)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+recordingColumns,
+		userID, fileKey, filename, duration, size))
+
+	if err == nil {
+		// Process audio in the background (transcode + pitch detection)
+		err = enqueueProcessing(ctx, tx, recording.ID)
+	}
+	if err == nil && link != nil {
+		err = link(ctx, tx, &recording)
+	}
+	if err == nil {
+		_, err = achievements.Evaluate(ctx, tx, userID)
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+
+	var ingestErr *ingestError
+	if errors.As(err, &ingestErr) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, &ingestError{status: http.StatusInternalServerError, message: "Failed to save recording metadata", err: err}
+	}
+
+	return &recording, nil
+}
+
+// respondIngestError writes the response for an error from ingestRecording
+func respondIngestError(c *gin.Context, err error) {
+	var ingestErr *ingestError
+	if !errors.As(err, &ingestErr) {
+		ingestErr = &ingestError{status: http.StatusInternalServerError, message: "Failed to save recording", err: err}
+	}
+
+	if ingestErr.status >= http.StatusInternalServerError {
+		log.Printf("Upload failed: %v", ingestErr)
+	}
+
+	c.JSON(ingestErr.status, models.APIResponse{
+		Success: false,
+		Error:   ingestErr.message,
+		Code:    ingestErr.code,
+	})
+}
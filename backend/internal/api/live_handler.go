@@ -0,0 +1,347 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+	"voice-training-app/internal/audio"
+	"voice-training-app/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	LiveMaxMessageBytes = 1 << 20 // One binary message of PCM; ~5s of f32 at 48 kHz
+	LiveIdleTimeout     = 60 * time.Second
+	LiveMinSampleRate   = 8000
+	LiveMaxSampleRate   = 96000
+)
+
+// AllowedOrigins lists the browser origins allowed to open WebSockets; set by main
+var AllowedOrigins []string
+
+var liveUpgrader = websocket.Upgrader{
+	ReadBufferSize:  64 * 1024,
+	WriteBufferSize: 16 * 1024,
+	CheckOrigin:     checkLiveOrigin,
+}
+
+// checkLiveOrigin accepts non-browser clients, same-host pages and the CORS origins
+func checkLiveOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	return slices.Contains(AllowedOrigins, origin)
+}
+
+// liveFrame is one pitch frame pushed to the client
+type liveFrame struct {
+	Time       float64  `json:"t"`
+	F0         *float64 `json:"f0_hz"` // null when unvoiced
+	Confidence float64  `json:"confidence"`
+	LoudnessDB float64  `json:"loudness_db"`
+	InTarget   *bool    `json:"in_target"` // null when unvoiced or no target is set
+}
+
+// liveTarget is the pitch range the speaker is aiming for
+type liveTarget struct {
+	MinHz float64 `json:"min_hz"`
+	MaxHz float64 `json:"max_hz"`
+}
+
+func (t *liveTarget) contains(f0 float64) bool {
+	return f0 >= t.MinHz && f0 <= t.MaxHz
+}
+
+// liveMessage is a control message in either direction
+type liveMessage struct {
+	Type       string            `json:"type"`
+	Frames     []liveFrame       `json:"frames,omitempty"`
+	Target     *liveTarget       `json:"target,omitempty"`
+	SampleRate int               `json:"sample_rate,omitempty"`
+	HopSeconds float64           `json:"hop_seconds,omitempty"`
+	Latency    float64           `json:"latency_seconds,omitempty"`
+	Recording  *models.Recording `json:"recording,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Code       string            `json:"code,omitempty"`
+}
+
+// Live message types
+const (
+	liveTypeReady  = "ready"  // Server: stream parameters after the upgrade
+	liveTypeFrames = "frames" // Server: pitch frames for the audio received
+	liveTypeTarget = "target" // Client: replace the target range
+	liveTypeStop   = "stop"   // Client: end the session
+	liveTypeSaved  = "saved"  // Server: the session was stored as a recording
+	liveTypeError  = "error"  // Server: the session failed
+)
+
+// LivePitch upgrades to a WebSocket that reports pitch while the user speaks.
+//
+// Query parameters: sample_rate (required), encoding (f32 or s16, default
+// f32), target_min_hz and target_max_hz (optional; default to the target band
+// of the user's voice profile) and save=true to store the session as a
+// recording when it ends. Pitch is tracked in the profile's range. Binary messages carry mono
+// little-endian PCM; each is answered with a "frames" message. A text message
+// {"type":"target","target":{...}} changes the target and {"type":"stop"}
+// ends the session.
+func LivePitch(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	sampleRate, err := strconv.Atoi(c.Query("sample_rate"))
+	if err != nil || sampleRate < LiveMinSampleRate || sampleRate > LiveMaxSampleRate {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "sample_rate must be between 8000 and 96000",
+		})
+		return
+	}
+
+	encoding := c.DefaultQuery("encoding", audio.PCMFloat32)
+	if encoding != audio.PCMFloat32 && encoding != audio.PCMInt16 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "encoding must be f32 or s16",
+		})
+		return
+	}
+
+	var target *liveTarget
+	if c.Query("target_min_hz") != "" || c.Query("target_max_hz") != "" {
+		minHz, errMin := strconv.ParseFloat(c.Query("target_min_hz"), 64)
+		maxHz, errMax := strconv.ParseFloat(c.Query("target_max_hz"), 64)
+		if errMin != nil || errMax != nil || minHz <= 0 || maxHz < minHz {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "target_min_hz and target_max_hz must form a valid range",
+			})
+			return
+		}
+		target = &liveTarget{MinHz: minHz, MaxHz: maxHz}
+	}
+
+	profile, err := loadVoiceProfile(context.Background(), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch voice profile",
+		})
+		return
+	}
+	if band := pitchBand(profile.TargetPitchMinHz, profile.TargetPitchMaxHz); target == nil && band != nil {
+		target = &liveTarget{MinHz: band.MinHz, MaxHz: band.MaxHz}
+	}
+	pitchRange := voicePitchRange(profile)
+
+	conn, err := liveUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written the error response
+		return
+	}
+	defer conn.Close()
+
+	session := &liveSession{
+		conn:       conn,
+		userID:     userID.(string),
+		sampleRate: sampleRate,
+		encoding:   encoding,
+		target:     target,
+		stream:     audio.NewPitchStream(sampleRate, pitchRange.MinHz, pitchRange.MaxHz),
+		maxSeconds: audio.DurationLimitsFromEnv().Max,
+	}
+	if c.Query("save") == "true" {
+		if err := session.startRecording(); err != nil {
+			log.Printf("Failed to start live recording: %v", err)
+			session.send(liveMessage{Type: liveTypeError, Error: "Failed to start recording"})
+			return
+		}
+	}
+	defer session.discardRecording()
+
+	session.run()
+}
+
+// liveSession is the state of one live pitch WebSocket
+type liveSession struct {
+	conn       *websocket.Conn
+	userID     string
+	sampleRate int
+	encoding   string
+	target     *liveTarget
+	stream     *audio.PitchStream
+	maxSeconds float64
+
+	wavPath string
+	wav     *audio.WAVWriter
+}
+
+func (s *liveSession) run() {
+	s.conn.SetReadLimit(LiveMaxMessageBytes)
+	s.send(liveMessage{
+		Type:       liveTypeReady,
+		SampleRate: s.sampleRate,
+		HopSeconds: s.stream.HopSeconds(),
+		Latency:    s.stream.LatencySeconds(),
+		Target:     s.target,
+	})
+
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(LiveIdleTimeout))
+		kind, data, err := s.conn.ReadMessage()
+		if err != nil {
+			// Closing without "stop" abandons the session
+			return
+		}
+
+		switch kind {
+		case websocket.BinaryMessage:
+			if !s.handleAudio(data) {
+				return
+			}
+		case websocket.TextMessage:
+			var msg liveMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				s.send(liveMessage{Type: liveTypeError, Error: "Invalid control message"})
+				continue
+			}
+
+			switch msg.Type {
+			case liveTypeTarget:
+				if msg.Target != nil && (msg.Target.MinHz <= 0 || msg.Target.MaxHz < msg.Target.MinHz) {
+					s.send(liveMessage{Type: liveTypeError, Error: "Invalid target range"})
+					continue
+				}
+				s.target = msg.Target
+			case liveTypeStop:
+				s.finish()
+				return
+			default:
+				s.send(liveMessage{Type: liveTypeError, Error: "Unknown message type " + msg.Type})
+			}
+		}
+	}
+}
+
+// handleAudio analyzes one PCM message and reports its frames. It returns
+// false when the session has to end.
+func (s *liveSession) handleAudio(data []byte) bool {
+	samples, err := audio.DecodePCM(data, s.encoding)
+	if err != nil {
+		s.send(liveMessage{Type: liveTypeError, Error: err.Error()})
+		return true
+	}
+
+	if s.wav != nil {
+		if s.wav.Duration()+float64(len(samples))/float64(s.sampleRate) > s.maxSeconds {
+			s.send(liveMessage{Type: liveTypeError, Error: "Session exceeds the maximum recording length", Code: "too_long"})
+			s.finish()
+			return false
+		}
+		if err := s.wav.WriteSamples(samples); err != nil {
+			log.Printf("Failed to write live recording: %v", err)
+			s.send(liveMessage{Type: liveTypeError, Error: "Failed to record audio"})
+			return false
+		}
+	}
+
+	frames := s.stream.Write(samples)
+	if len(frames) == 0 {
+		return true
+	}
+
+	out := make([]liveFrame, len(frames))
+	for i, f := range frames {
+		out[i] = liveFrame{Time: f.Time, Confidence: f.Confidence, LoudnessDB: f.LoudnessDB}
+		if f.Voiced {
+			f0 := f.F0
+			out[i].F0 = &f0
+			if s.target != nil {
+				inTarget := s.target.contains(f0)
+				out[i].InTarget = &inTarget
+			}
+		}
+	}
+
+	return s.send(liveMessage{Type: liveTypeFrames, Frames: out}) == nil
+}
+
+func (s *liveSession) startRecording() error {
+	file, err := os.CreateTemp("", "live-*.wav")
+	if err != nil {
+		return err
+	}
+	s.wavPath = file.Name()
+	file.Close()
+
+	s.wav, err = audio.CreateWAV(s.wavPath, s.sampleRate)
+	return err
+}
+
+// finish stores the session as a recording when it was being saved
+func (s *liveSession) finish() {
+	if s.wav == nil {
+		s.conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		return
+	}
+
+	err := s.wav.Close()
+	s.wav = nil
+	if err != nil {
+		log.Printf("Failed to finalize live recording: %v", err)
+		s.send(liveMessage{Type: liveTypeError, Error: "Failed to save recording"})
+		return
+	}
+
+	filename := "live-" + time.Now().UTC().Format("20060102-150405") + ".wav"
+	recording, err := ingestRecording(context.Background(), s.userID, s.wavPath, filename, "audio/wav", nil)
+	if err != nil {
+		log.Printf("Failed to save live recording: %v", err)
+		msg := liveMessage{Type: liveTypeError, Error: "Failed to save recording"}
+		var ingestErr *ingestError
+		if errors.As(err, &ingestErr) && ingestErr.status < http.StatusInternalServerError {
+			msg.Error, msg.Code = ingestErr.message, ingestErr.code
+		}
+		s.send(msg)
+		return
+	}
+
+	s.send(liveMessage{Type: liveTypeSaved, Recording: recording})
+	s.conn.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// discardRecording removes the temporary WAV once the session is over
+func (s *liveSession) discardRecording() {
+	if s.wav != nil {
+		s.wav.Close()
+		s.wav = nil
+	}
+	if s.wavPath != "" {
+		os.Remove(s.wavPath)
+	}
+}
+
+func (s *liveSession) send(msg liveMessage) error {
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return s.conn.WriteJSON(msg)
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"voice-training-app/internal/audio"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DefaultPitchPoints = 1000 // Points returned when max_points is not given
+	MaxPitchPoints     = 5000 // Upper bound on max_points
+)
+
+// GetPitchContour returns the stored pitch contour of a recording.
+// Optional query parameters: from and to (seconds) select a time range,
+// max_points downsamples the contour into at most that many points.
+func GetPitchContour(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	recordingID := c.Param("id")
+
+	from, err := parseFloatQuery(c, "from")
+	if err != nil || (from != nil && *from < 0) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid from parameter",
+		})
+		return
+	}
+
+	to, err := parseFloatQuery(c, "to")
+	if err != nil || (to != nil && from != nil && *to < *from) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid to parameter",
+		})
+		return
+	}
+
+	maxPoints := DefaultPitchPoints
+	if raw := c.Query("max_points"); raw != "" {
+		maxPoints, err = strconv.Atoi(raw)
+		if err != nil || maxPoints < 1 || maxPoints > MaxPitchPoints {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "max_points must be between 1 and " + strconv.Itoa(MaxPitchPoints),
+			})
+			return
+		}
+	}
+
+	// Verify ownership
+	var owned bool
+	err = database.DB.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM recordings WHERE id = $1 AND user_id = $2)`,
+		recordingID, userID).Scan(&owned)
+	if err != nil || !owned {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Recording not found",
+		})
+		return
+	}
+
+	rows, err := database.DB.Query(context.Background(),
+		`SELECT time_sec, f0_hz, confidence, voiced, f1_hz, f2_hz, f3_hz
+		 FROM pitch_frames
+		 WHERE recording_id = $1
+		   AND ($2::float IS NULL OR time_sec >= $2)
+		   AND ($3::float IS NULL OR time_sec <= $3)
+		 ORDER BY frame_index`,
+		recordingID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch pitch contour",
+		})
+		return
+	}
+	defer rows.Close()
+
+	points := []models.PitchPoint{}
+	for rows.Next() {
+		var p models.PitchPoint
+		if err := rows.Scan(&p.Time, &p.F0Hz, &p.Confidence, &p.Voiced, &p.F1Hz, &p.F2Hz, &p.F3Hz); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to read pitch contour",
+			})
+			return
+		}
+		points = append(points, p)
+	}
+	if rows.Err() != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to read pitch contour",
+		})
+		return
+	}
+
+	contour := models.PitchContour{
+		RecordingID: recordingID,
+		TotalFrames: len(points),
+		Points:      points,
+	}
+	if len(points) > 0 {
+		contour.From = points[0].Time
+		contour.To = points[len(points)-1].Time
+	}
+	if len(points) > maxPoints {
+		contour.Points = downsamplePitch(points, maxPoints)
+		contour.Downsampled = true
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"contour": contour,
+		},
+	})
+}
+
+// downsamplePitch groups consecutive frames into maxPoints buckets. Each bucket
+// reports the median voiced F0 and formants and the mean confidence of its frames.
+func downsamplePitch(points []models.PitchPoint, maxPoints int) []models.PitchPoint {
+	result := make([]models.PitchPoint, 0, maxPoints)
+
+	for b := 0; b < maxPoints; b++ {
+		start := b * len(points) / maxPoints
+		end := (b + 1) * len(points) / maxPoints
+		if start == end {
+			continue
+		}
+		bucket := points[start:end]
+
+		var f0, f1, f2, f3 []float64
+		confidence := 0.0
+		for _, p := range bucket {
+			confidence += p.Confidence
+			if !p.Voiced {
+				continue
+			}
+			f0 = appendValue(f0, p.F0Hz)
+			f1 = appendValue(f1, p.F1Hz)
+			f2 = appendValue(f2, p.F2Hz)
+			f3 = appendValue(f3, p.F3Hz)
+		}
+
+		result = append(result, models.PitchPoint{
+			Time:       (bucket[0].Time + bucket[len(bucket)-1].Time) / 2,
+			F0Hz:       medianOf(f0),
+			Confidence: confidence / float64(len(bucket)),
+			Voiced:     len(f0) > 0,
+			F1Hz:       medianOf(f1),
+			F2Hz:       medianOf(f2),
+			F3Hz:       medianOf(f3),
+		})
+	}
+
+	return result
+}
+
+func appendValue(values []float64, v *float64) []float64 {
+	if v == nil {
+		return values
+	}
+	return append(values, *v)
+}
+
+// medianOf returns the median of values, or nil when there are none
+func medianOf(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sort.Float64s(values)
+	median := values[len(values)/2]
+	if len(values)%2 == 0 {
+		median = (values[len(values)/2-1] + values[len(values)/2]) / 2
+	}
+
+	return &median
+}
+
+// savePitchContour replaces the stored contour of a recording with the pitch
+// frames and the formants measured at them
+func savePitchContour(ctx context.Context, tx pgx.Tx, recordingID string, pitch *audio.PitchTrack, formants *audio.FormantTrack) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM pitch_frames WHERE recording_id = $1`, recordingID); err != nil {
+		return err
+	}
+
+	_, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"pitch_frames"},
+		[]string{"recording_id", "frame_index", "time_sec", "f0_hz", "confidence", "voiced", "f1_hz", "f2_hz", "f3_hz"},
+		pgx.CopyFromSlice(len(pitch.Frames), func(i int) ([]any, error) {
+			f := pitch.Frames[i]
+			var f0 *float64
+			if f.Voiced {
+				f0 = &f.F0
+			}
+
+			var fm audio.FormantFrame
+			if i < len(formants.Frames) {
+				fm = formants.Frames[i]
+			}
+
+			return []any{recordingID, i, f.Time, f0, f.Confidence, f.Voiced,
+				nonZero(fm.F1), nonZero(fm.F2), nonZero(fm.F3)}, nil
+		}))
+
+	return err
+}
+
+// nonZero maps an "absent" zero measurement to NULL
+func nonZero(v float64) *float64 {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+// parseFloatQuery reads an optional float query parameter. NaN and infinities,
+// which ParseFloat accepts, are rejected.
+func parseFloatQuery(c *gin.Context, name string) (*float64, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, fmt.Errorf("%s must be a finite number", name)
+	}
+
+	return &v, nil
+}
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"voice-training-app/internal/achievements"
+	"voice-training-app/internal/audio"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/gamification"
+	"voice-training-app/internal/jobs"
+	"voice-training-app/internal/models"
+	"voice-training-app/internal/scoring"
+	"voice-training-app/internal/storage"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ProcessRecordingJob is the job kind that analyzes an uploaded recording
+const ProcessRecordingJob = "process_recording"
+
+type processRecordingPayload struct {
+	RecordingID string `json:"recording_id"`
+}
+
+// RegisterJobs registers the background job handlers owned by the API
+func RegisterJobs() {
+	jobs.Register(ProcessRecordingJob, handleProcessRecording)
+}
+
+// enqueueProcessing queues analysis of a recording
+func enqueueProcessing(ctx context.Context, db jobs.Execer, recordingID string) error {
+	return jobs.Enqueue(ctx, db, ProcessRecordingJob, ProcessRecordingJob+":"+recordingID,
+		processRecordingPayload{RecordingID: recordingID})
+}
+
+// RequeueUnprocessedRecordings queues analysis for recordings that are still
+// pending or processing but have no job on record, e.g. uploads lost in a restart
+func RequeueUnprocessedRecordings(ctx context.Context) (int64, error) {
+	tag, err := database.DB.Exec(ctx,
+		`INSERT INTO jobs (kind, payload, dedupe_key, max_attempts)
+		 SELECT $1, jsonb_build_object('recording_id', r.id), $1 || ':' || r.id, $2
+		 FROM recordings r
+		 WHERE r.status IN ('pending', 'processing')
+		   AND NOT EXISTS (SELECT 1 FROM jobs j WHERE j.dedupe_key = $1 || ':' || r.id)
+		 ON CONFLICT (dedupe_key) WHERE dedupe_key IS NOT NULL AND status IN ('queued', 'running')
+		 DO NOTHING`,
+		ProcessRecordingJob, jobs.DefaultMaxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func handleProcessRecording(ctx context.Context, job *jobs.Job) error {
+	var payload processRecordingPayload
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+
+	var fileKey, userID string
+	err := database.DB.QueryRow(ctx,
+		`SELECT file_path, user_id FROM recordings WHERE id = $1`, payload.RecordingID).Scan(&fileKey, &userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Deleted before it was processed; nothing left to do
+		log.Printf("Skipping processing of deleted recording %s", payload.RecordingID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load recording: %w", err)
+	}
+
+	_, err = database.DB.Exec(ctx,
+		`UPDATE recordings SET status = $1, updated_at = NOW() WHERE id = $2`,
+		models.RecordingStatusProcessing, payload.RecordingID)
+	if err != nil {
+		return fmt.Errorf("failed to mark recording processing: %w", err)
+	}
+
+	if err := processRecording(ctx, payload.RecordingID, userID, fileKey); err != nil {
+		// Go back to pending while retries remain, fail on the last attempt
+		status := models.RecordingStatusPending
+		if job.Attempts >= job.MaxAttempts || jobs.IsPermanent(err) {
+			status = models.RecordingStatusFailed
+		}
+		markRecordingFailure(payload.RecordingID, status, err)
+		return err
+	}
+
+	return nil
+}
+
+// markRecordingFailure records why processing of a recording failed. A failed
+// ffmpeg or ffprobe run is reported by a generic reason, its stderr kept only
+// in failure_detail, which is not shown to users.
+func markRecordingFailure(recordingID, status string, cause error) {
+	reason := cause.Error()
+	var detail *string
+	var cmdErr *audio.CommandError
+	if errors.As(cause, &cmdErr) {
+		reason = cmdErr.Reason()
+		if cmdErr.Stderr != "" {
+			detail = &cmdErr.Stderr
+		}
+	}
+
+	_, err := database.DB.Exec(context.Background(),
+		`UPDATE recordings SET status = $1, failure_reason = $2, failure_detail = $3, updated_at = NOW() WHERE id = $4`,
+		status, reason, detail, recordingID)
+	if err != nil {
+		log.Printf("Failed to record processing failure for recording %s: %v", recordingID, err)
+	}
+}
+
+// processRecording transcodes the upload stored under fileKey, analyzes it in
+// the pitch range of its user's voice profile and stores the duration, voice
+// activity segments, pitch and formant contours, summaries and voice quality
+// measures, scores it against its exercise and awards the practice XP. The WAV
+// and renderings are written back to storage next to the upload.
+func processRecording(ctx context.Context, recordingID, userID, fileKey string) error {
+	workDir, err := os.MkdirTemp("", "recording-")
+	if err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath, err := storage.Fetch(ctx, storage.Default, fileKey, workDir)
+	if errors.Is(err, storage.ErrNotFound) {
+		return jobs.Permanent(fmt.Errorf("upload %s is missing from storage", fileKey))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch upload: %w", err)
+	}
+
+	profile, err := loadVoiceProfile(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load voice profile: %w", err)
+	}
+	opts := audio.DefaultProcessOptions()
+	opts.PitchRange = voicePitchRange(profile)
+
+	analysis, err := audio.ProcessAudioFile(ctx, inputPath, workDir, opts)
+	var durationErr *audio.DurationError
+	if errors.As(err, &durationErr) {
+		// Retrying will not change the length of the recording
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return fmt.Errorf("audio processing failed: %w", err)
+	}
+
+	// Unvoiced recordings keep NULL pitch statistics
+	summary := analysis.Pitch.Summary
+	var median, mean, minHz, maxHz, p10, p90, stdDev *float64
+	if summary.VoicedFrames > 0 {
+		median, mean = &summary.MedianHz, &summary.MeanHz
+		minHz, maxHz = &summary.MinHz, &summary.MaxHz
+		p10, p90 = &summary.P10Hz, &summary.P90Hz
+		stdDev = &summary.StdDevHz
+	}
+
+	// Recordings without speech keep NULL speech boundaries
+	vad := analysis.VAD
+	formants := analysis.Formants
+	var speechStart, speechEnd *float64
+	if vad.ActiveSeconds > 0 {
+		speechStart, speechEnd = &vad.SpeechStart, &vad.SpeechEnd
+	}
+
+	// Voice quality needs enough periods, and each measure something to
+	// measure; otherwise it stays NULL
+	var jitterLocal, jitterRAP, shimmerLocal, shimmerAPQ, hnr *float64
+	if q := analysis.Quality; q != nil {
+		jitterLocal, jitterRAP = q.JitterLocal, q.JitterRAP
+		shimmerLocal, shimmerAPQ = q.ShimmerLocal, q.ShimmerAPQ11
+		hnr = q.HNR
+	}
+
+	// Recordings made for an exercise are scored against it
+	score, err := scoreRecording(ctx, recordingID, profile, analysis)
+	if err != nil {
+		return err
+	}
+	var exerciseScore *int
+	var breakdown []byte
+	if score != nil {
+		exerciseScore = &score.Score
+		if breakdown, err = json.Marshal(score.Criteria); err != nil {
+			return fmt.Errorf("failed to encode score breakdown: %w", err)
+		}
+	}
+
+	if err := storeArtifacts(ctx, fileKey, analysis); err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Update recording with duration, speaking time, pitch, formant, quality and score data
+	var sessionID *string
+	var createdAt time.Time
+	err = tx.QueryRow(ctx,
+		`UPDATE recordings
+		 SET duration = $1, active_seconds = $2, speech_start_sec = $3, speech_end_sec = $4,
+		     pitch_hz = $5, pitch_mean_hz = $6, pitch_min_hz = $7, pitch_max_hz = $8,
+		     pitch_p10_hz = $9, pitch_p90_hz = $10, pitch_stddev_hz = $11, voiced_ratio = $12,
+		     f1_hz = $13, f2_hz = $14, f3_hz = $15,
+		     jitter_local_pct = $16, jitter_rap_pct = $17, shimmer_local_pct = $18,
+		     shimmer_apq11_pct = $19, hnr_db = $20, exercise_score = $21, score_breakdown = $22,
+		     status = $23, failure_reason = NULL, failure_detail = NULL, processed_at = NOW(), updated_at = NOW()
+		 WHERE id = $24
+		 RETURNING session_id, created_at`,
+		analysis.Duration, vad.ActiveSeconds, speechStart, speechEnd,
+		median, mean, minHz, maxHz, p10, p90, stdDev, summary.VoicedRatio(),
+		nonZero(formants.MedianF1), nonZero(formants.MedianF2), nonZero(formants.MedianF3),
+		jitterLocal, jitterRAP, shimmerLocal, shimmerAPQ, hnr, exerciseScore, breakdown,
+		models.RecordingStatusDone, recordingID).Scan(&sessionID, &createdAt)
+	if err != nil {
+		return fmt.Errorf("failed to update pitch: %w", err)
+	}
+
+	// Store the full contour alongside the summary
+	if err := savePitchContour(ctx, tx, recordingID, analysis.Pitch, formants); err != nil {
+		return fmt.Errorf("failed to store pitch contour: %w", err)
+	}
+
+	if err := saveSegments(ctx, tx, recordingID, vad.Segments); err != nil {
+		return fmt.Errorf("failed to store voice activity segments: %w", err)
+	}
+
+	// Credit the practice once, on the day it was recorded
+	xp := gamification.RecordingXP(vad.ActiveSeconds)
+	if exerciseScore != nil && xp > 0 {
+		xp += gamification.ScoreXP(*exerciseScore)
+	}
+	_, err = gamification.Award(ctx, tx, gamification.Event{
+		UserID:     userID,
+		Source:     gamification.SourceRecording,
+		SourceID:   recordingID,
+		XP:         xp,
+		OccurredAt: createdAt,
+	})
+	if err != nil {
+		return err
+	}
+	if sessionID != nil {
+		if err := refreshSessionXP(ctx, tx, *sessionID); err != nil {
+			return fmt.Errorf("failed to update session XP: %w", err)
+		}
+	}
+	if _, err := achievements.Evaluate(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit analysis: %w", err)
+	}
+
+	log.Printf("Processed recording %s: WAV=%s, Duration=%.1fs (%.1fs speech), Median pitch=%.2f Hz (%d/%d voiced frames)",
+		recordingID, audio.WAVKeyFor(fileKey), analysis.Duration, vad.ActiveSeconds,
+		summary.MedianHz, summary.VoicedFrames, summary.TotalFrames)
+	return nil
+}
+
+// scoreRecording scores an analyzed recording against the exercise it was
+// made for and the target bands of the user's voice profile. It returns nil
+// for recordings that are not linked to an exercise.
+func scoreRecording(ctx context.Context, recordingID string, profile *models.VoiceProfile, analysis *audio.Analysis) (*scoring.Result, error) {
+	var exercise models.Exercise
+	err := scanExercise(&exercise, database.DB.QueryRow(ctx,
+		`SELECT `+exerciseColumns+`
+		 FROM recordings r
+		 JOIN exercises e ON e.id = r.exercise_id
+		 JOIN exercise_categories c ON c.id = e.category_id
+		 WHERE r.id = $1`,
+		recordingID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exercise: %w", err)
+	}
+
+	result := scoring.Score(scoring.TargetFor(&exercise, profile), analysis.Pitch, analysis.Formants, analysis.VAD)
+	return &result, nil
+}
+
+// storeArtifacts uploads the WAV and renderings produced for the upload under fileKey
+func storeArtifacts(ctx context.Context, fileKey string, analysis *audio.Analysis) error {
+	artifacts := []struct {
+		key, path, contentType string
+	}{
+		{audio.WAVKeyFor(fileKey), analysis.WAVPath, "audio/wav"},
+		{audio.WaveformKeyFor(fileKey), analysis.WaveformPath, "application/json"},
+		{audio.SpectrogramKeyFor(fileKey), analysis.SpectrogramPath, "image/png"},
+	}
+
+	for _, a := range artifacts {
+		if err := storage.PutFile(ctx, storage.Default, a.key, a.path, a.contentType); err != nil {
+			return fmt.Errorf("failed to store %s: %w", a.key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/gamification"
+	"voice-training-app/internal/models"
+	"voice-training-app/internal/progress"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProgress returns the authenticated user's activity in day, week or month
+// buckets, with trend lines through their recordings.
+//
+// Query parameters: bucket (day, week or month, default day), from and to
+// (YYYY-MM-DD local dates, to defaults to today and from to 30 days, 12 weeks
+// or 12 months before it) and timezone (IANA name, default the user's).
+func GetProgress(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	ctx := context.Background()
+	user, err := loadUser(ctx, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+		return
+	}
+
+	q := progress.Query{
+		UserID:   user.ID,
+		Bucket:   c.DefaultQuery("bucket", progress.BucketDay),
+		Timezone: c.DefaultQuery("timezone", user.Timezone),
+	}
+	if !progress.ValidBucket(q.Bucket) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "bucket must be day, week or month",
+		})
+		return
+	}
+	if !gamification.ValidTimezone(q.Timezone) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid timezone",
+		})
+		return
+	}
+
+	q.To = gamification.Day(time.Now(), gamification.LoadLocation(q.Timezone))
+	if to := c.Query("to"); to != "" {
+		if q.To, err = time.Parse(time.DateOnly, to); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "to must be a YYYY-MM-DD date",
+			})
+			return
+		}
+	}
+	q.From = progress.DefaultFrom(q.Bucket, q.To)
+	if from := c.Query("from"); from != "" {
+		if q.From, err = time.Parse(time.DateOnly, from); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "from must be a YYYY-MM-DD date",
+			})
+			return
+		}
+	}
+	if q.From.After(q.To) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "from must not be after to",
+		})
+		return
+	}
+	if progress.BucketCount(q.Bucket, q.From, q.To) > progress.MaxBuckets {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Range spans more than %d buckets; use a larger bucket", progress.MaxBuckets),
+		})
+		return
+	}
+
+	if q.Profile, err = loadVoiceProfile(ctx, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch voice profile",
+		})
+		return
+	}
+
+	report, err := progress.Load(ctx, database.DB, q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch progress",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"progress": report,
+		},
+	})
+}
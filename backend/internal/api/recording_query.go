@@ -0,0 +1,237 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"voice-training-app/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultRecordingPageSize = 20
+	MaxRecordingPageSize     = 100
+)
+
+// recordingSorts maps the sort options of ListRecordings to the column they
+// order by. Ties, and created_at itself, are broken by (created_at, id).
+var recordingSorts = map[string]string{
+	"created_at":     "created_at",
+	"duration":       "duration",
+	"pitch_hz":       "pitch_hz",
+	"exercise_score": "exercise_score",
+}
+
+// recordingCursor is the position after the last recording of a page. Sort
+// names the ordering it belongs to, so a cursor cannot be replayed against
+// another one.
+type recordingCursor struct {
+	Sort      string    `json:"s"`
+	Value     *float64  `json:"v,omitempty"` // Sort column of the last recording; nil when NULL or sorting by created_at
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+func (cur recordingCursor) encode() string {
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeRecordingCursor(s string) (*recordingCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var cur recordingCursor
+	if err := json.Unmarshal(raw, &cur); err != nil {
+		return nil, err
+	}
+	if _, err := uuid.Parse(cur.ID); err != nil {
+		return nil, err
+	}
+	return &cur, nil
+}
+
+// recordingQuery is a page of ListRecordings as SQL
+type recordingQuery struct {
+	where  []string
+	args   []any
+	column string // Sort column
+	desc   bool
+	sort   string // Sort option and direction, e.g. "pitch_hz:desc"
+	limit  int
+}
+
+// bind adds v as the next query argument and returns its placeholder
+func (q *recordingQuery) bind(v any) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// parseRecordingQuery reads the filters, sort order, page size and cursor of
+// ListRecordings. Date-only bounds are days in loc, the user's timezone. When
+// a parameter is invalid it returns a message describing it instead.
+func parseRecordingQuery(c *gin.Context, userID string, loc *time.Location) (*recordingQuery, string) {
+	q := &recordingQuery{limit: DefaultRecordingPageSize}
+	q.where = append(q.where, "user_id = "+q.bind(userID))
+
+	if raw := c.Query("status"); raw != "" {
+		if !models.ValidRecordingStatus(raw) {
+			return nil, "Invalid status filter"
+		}
+		q.where = append(q.where, "status = "+q.bind(raw))
+	}
+
+	for _, f := range []struct{ param, column string }{
+		{"session_id", "session_id"},
+		{"exercise_id", "exercise_id"},
+	} {
+		raw := c.Query(f.param)
+		if raw == "" {
+			continue
+		}
+		if _, err := uuid.Parse(raw); err != nil {
+			return nil, fmt.Sprintf("Invalid %s", f.param)
+		}
+		q.where = append(q.where, f.column+" = "+q.bind(raw))
+	}
+
+	// Repeated tag parameters select the recordings carrying every one of them
+	if names := normalizeTags(c.QueryArray("tag")); len(names) > 0 {
+		q.where = append(q.where, `id IN (
+		   SELECT rt.recording_id FROM recording_tags rt JOIN tags t ON t.id = rt.tag_id
+		   WHERE t.name = ANY(`+q.bind(names)+`::text[])
+		   GROUP BY rt.recording_id HAVING COUNT(*) = `+q.bind(len(names))+`)`)
+	}
+
+	// The search text matches literally, so % and _ are not wildcards
+	if raw := strings.TrimSpace(c.Query("q")); raw != "" {
+		p := "lower(" + q.bind(raw) + ")"
+		var matches []string
+		for _, column := range []string{"title", "notes", "original_filename"} {
+			matches = append(matches, "strpos(lower("+column+"), "+p+") > 0")
+		}
+		q.where = append(q.where, "("+strings.Join(matches, " OR ")+")")
+	}
+
+	// A date-only bound covers its whole local day
+	for _, f := range []struct {
+		param, op string
+		days      int
+	}{{"from", ">=", 0}, {"to", "<", 1}} {
+		raw := c.Query(f.param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if t, err = time.ParseInLocation(time.DateOnly, raw, loc); err != nil {
+				return nil, fmt.Sprintf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", f.param)
+			}
+			t = t.AddDate(0, 0, f.days)
+		} else if f.op == "<" {
+			f.op = "<="
+		}
+		q.where = append(q.where, "created_at "+f.op+" "+q.bind(t.UTC()))
+	}
+
+	for _, f := range []struct{ param, op string }{{"min_pitch_hz", ">="}, {"max_pitch_hz", "<="}} {
+		v, err := parseFloatQuery(c, f.param)
+		if err != nil || (v != nil && *v <= 0) {
+			return nil, fmt.Sprintf("%s must be a positive number", f.param)
+		}
+		if v != nil {
+			q.where = append(q.where, "pitch_hz "+f.op+" "+q.bind(*v))
+		}
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > MaxRecordingPageSize {
+			return nil, fmt.Sprintf("limit must be between 1 and %d", MaxRecordingPageSize)
+		}
+		q.limit = limit
+	}
+
+	sort := c.DefaultQuery("sort", "created_at")
+	column, ok := recordingSorts[sort]
+	if !ok {
+		return nil, "sort must be one of created_at, duration, pitch_hz, exercise_score"
+	}
+	order := c.DefaultQuery("order", "desc")
+	if order != "asc" && order != "desc" {
+		return nil, "order must be asc or desc"
+	}
+	q.column, q.desc, q.sort = column, order == "desc", sort+":"+order
+
+	if raw := c.Query("cursor"); raw != "" {
+		cur, err := decodeRecordingCursor(raw)
+		if err != nil || cur.Sort != q.sort {
+			return nil, "Invalid cursor"
+		}
+		q.where = append(q.where, q.after(cur))
+	}
+
+	return q, ""
+}
+
+// after returns the condition selecting the recordings that follow cur in
+// the query's order. NULL sort values come last in either direction.
+func (q *recordingQuery) after(cur *recordingCursor) string {
+	cmp := ">"
+	if q.desc {
+		cmp = "<"
+	}
+	tie := "(created_at, id) " + cmp + " (" + q.bind(cur.CreatedAt) + "::timestamp, " + q.bind(cur.ID) + "::uuid)"
+
+	switch {
+	case q.column == "created_at":
+		return tie
+	case cur.Value == nil:
+		return "(" + q.column + " IS NULL AND " + tie + ")"
+	}
+	v := q.bind(*cur.Value) + "::float8"
+	return "(" + q.column + " " + cmp + " " + v + " OR (" + q.column + " = " + v + " AND " + tie + ") OR " +
+		q.column + " IS NULL)"
+}
+
+// sql returns the statement selecting the page, with one extra row to tell
+// whether another page follows
+func (q *recordingQuery) sql() string {
+	dir := "ASC"
+	if q.desc {
+		dir = "DESC"
+	}
+	order := "created_at " + dir + ", id " + dir
+	if q.column != "created_at" {
+		order = q.column + " " + dir + " NULLS LAST, " + order
+	}
+
+	return `SELECT ` + recordingColumns + `
+		 FROM recordings
+		 WHERE ` + strings.Join(q.where, " AND ") + `
+		 ORDER BY ` + order + `
+		 LIMIT ` + strconv.Itoa(q.limit+1)
+}
+
+// cursorAfter returns the cursor continuing after r
+func (q *recordingQuery) cursorAfter(r *models.Recording) string {
+	cur := recordingCursor{Sort: q.sort, CreatedAt: r.CreatedAt, ID: r.ID}
+	switch q.column {
+	case "duration":
+		cur.Value = &r.Duration
+	case "pitch_hz":
+		cur.Value = r.PitchHz
+	case "exercise_score":
+		if r.ExerciseScore != nil {
+			v := float64(*r.ExerciseScore)
+			cur.Value = &v
+		}
+	}
+	return cur.encode()
+}
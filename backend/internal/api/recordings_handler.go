@@ -2,29 +2,51 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
-	"time"
+	"strings"
 	"voice-training-app/internal/audio"
 	"voice-training-app/internal/database"
+	"voice-training-app/internal/gamification"
 	"voice-training-app/internal/models"
+	"voice-training-app/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 const (
-	MaxUploadSize    = 50 * 1024 * 1024 // 50MB
-	UploadDir        = "uploads/recordings"
-	ProcessedDir     = "uploads/processed"
-	AllowedMimeTypes = "audio/webm,audio/mp4,audio/wav,audio/mpeg"
+	MaxUploadSize = 50 * 1024 * 1024 // 50MB
+	UploadPrefix  = "recordings"     // Storage key prefix of uploaded recordings
 )
 
-// UploadRecording handles audio file uploads
+// recordingColumns lists the recordings columns read by scanRecording, in scan order
+const recordingColumns = `id, user_id, session_id, exercise_id, file_path, original_filename, title, notes, duration, file_size,
+	pitch_hz, pitch_mean_hz, pitch_min_hz, pitch_max_hz, pitch_p10_hz, pitch_p90_hz, pitch_stddev_hz, voiced_ratio,
+	f1_hz, f2_hz, f3_hz, jitter_local_pct, jitter_rap_pct, shimmer_local_pct, shimmer_apq11_pct, hnr_db,
+	active_seconds, speech_start_sec, speech_end_sec, exercise_score, score_breakdown,
+	status, failure_reason, failure_detail, processed_at, created_at, updated_at`
+
+// scanRecording scans a row selected with recordingColumns into r
+func scanRecording(r *models.Recording, row pgx.Row) error {
+	return row.Scan(&r.ID, &r.UserID, &r.SessionID, &r.ExerciseID, &r.FilePath, &r.OriginalFilename,
+		&r.Title, &r.Notes, &r.Duration, &r.FileSize,
+		&r.PitchHz, &r.PitchMeanHz, &r.PitchMinHz, &r.PitchMaxHz,
+		&r.PitchP10Hz, &r.PitchP90Hz, &r.PitchStdDevHz, &r.VoicedRatio,
+		&r.F1Hz, &r.F2Hz, &r.F3Hz,
+		&r.JitterLocalPct, &r.JitterRAPPct, &r.ShimmerLocalPct, &r.ShimmerAPQ11Pct, &r.HNRDb,
+		&r.ActiveSeconds, &r.SpeechStartSec, &r.SpeechEndSec, &r.ExerciseScore, &r.ScoreBreakdown,
+		&r.Status, &r.FailureReason, &r.FailureDetail, &r.ProcessedAt, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// UploadRecording handles audio file uploads. Optional session_id and
+// exercise_id form fields link the recording to an active practice session
+// and to the exercise it was made for.
 func UploadRecording(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
 	userID, exists := c.Get("user_id")
@@ -65,25 +87,8 @@ func UploadRecording(c *gin.Context) {
 		return
 	}
 
-	// Generate unique filename
-	ext := filepath.Ext(header.Filename)
-	if ext == "" {
-		ext = ".webm" // Default extension
-	}
-	newFilename := fmt.Sprintf("%s-%d%s", uuid.New().String(), time.Now().Unix(), ext)
-	filePath := filepath.Join(UploadDir, newFilename)
-
-	// Create upload directory if it doesn't exist
-	if err := os.MkdirAll(UploadDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
-			Success: false,
-			Error:   "Failed to create upload directory",
-		})
-		return
-	}
-
-	// Save file to disk
-	dst, err := os.Create(filePath)
+	// Spool the upload to a temporary file so it can be sniffed and probed
+	tmp, err := os.CreateTemp("", "upload-*")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -91,11 +96,10 @@ func UploadRecording(c *gin.Context) {
 		})
 		return
 	}
-	defer dst.Close()
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
 
-	written, err := io.Copy(dst, file)
-	if err != nil {
-		os.Remove(filePath) // Clean up on error
+	if _, err := io.Copy(tmp, file); err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Error:   "Failed to write file",
@@ -103,45 +107,43 @@ func UploadRecording(c *gin.Context) {
 		return
 	}
 
-	// Save recording metadata to database
-	var recording models.Recording
-	err = database.DB.QueryRow(context.Background(),
-		`INSERT INTO recordings (user_id, file_path, original_filename, duration, file_size)
-		 VALUES ($1, $2, $3, $4, $5)
-		 RETURNING id, user_id, file_path, original_filename, duration, file_size, pitch_hz, created_at, updated_at`,
-		userID, filePath, header.Filename, 0.0, written).Scan(
-		&recording.ID, &recording.UserID, &recording.FilePath, &recording.OriginalFilename,
-		&recording.Duration, &recording.FileSize, &recording.PitchHz,
-		&recording.CreatedAt, &recording.UpdatedAt)
-
-	if err != nil {
-		os.Remove(filePath) // Clean up on error
+	if err := tmp.Close(); err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Error:   "Failed to save recording metadata",
+			Error:   "Failed to write file",
 		})
 		return
 	}
 
-	// Process audio asynchronously (transcode + pitch detection)
-	go func() {
-		wavPath, pitchHz, err := audio.ProcessAudioFile(filePath)
-		if err != nil {
-			log.Printf("Audio processing failed for recording %s: %v", recording.ID, err)
+	// Link the recording to the practice session it was made in and the
+	// exercise it was made for, if any
+	var sessionLink, exerciseLink ingestLinker
+	if sessionID := c.Request.FormValue("session_id"); sessionID != "" {
+		if err := checkUploadSession(context.Background(), userID.(string), sessionID); err != nil {
+			respondIngestError(c, err)
 			return
 		}
-
-		// Update recording with pitch data
-		_, err = database.DB.Exec(context.Background(),
-			`UPDATE recordings SET pitch_hz = $1 WHERE id = $2`,
-			pitchHz, recording.ID)
-
-		if err != nil {
-			log.Printf("Failed to update pitch for recording %s: %v", recording.ID, err)
-		} else {
-			log.Printf("Processed recording %s: WAV=%s, Pitch=%.2f Hz", recording.ID, wavPath, pitchHz)
+		sessionLink = func(ctx context.Context, tx pgx.Tx, recording *models.Recording) error {
+			return linkToSession(ctx, tx, sessionID, recording)
+		}
+	}
+	if exerciseID := c.Request.FormValue("exercise_id"); exerciseID != "" {
+		if err := checkUploadExercise(context.Background(), exerciseID); err != nil {
+			respondIngestError(c, err)
+			return
+		}
+		exerciseLink = func(ctx context.Context, tx pgx.Tx, recording *models.Recording) error {
+			return linkToExercise(ctx, tx, exerciseID, recording)
 		}
-	}()
+	}
+
+	// The exercise goes first, so that it counts towards an already completed session
+	recording, err := ingestRecording(context.Background(), userID.(string), tmp.Name(),
+		header.Filename, header.Header.Get("Content-Type"), linkAll(exerciseLink, sessionLink))
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusCreated, models.APIResponse{
 		Success: true,
@@ -151,7 +153,14 @@ func UploadRecording(c *gin.Context) {
 	})
 }
 
-// ListRecordings returns all recordings for the authenticated user
+// ListRecordings returns a page of the authenticated user's recordings.
+//
+// Query parameters: status, session_id, exercise_id, tag (repeatable; all
+// must match), q (searches title, notes and filename), from and to (RFC 3339
+// timestamps or YYYY-MM-DD dates in the user's timezone, inclusive),
+// min_pitch_hz and max_pitch_hz filter; sort (created_at, duration, pitch_hz
+// or exercise_score) and order (asc or desc, default desc) order; limit sets
+// the page size and cursor continues from the next_cursor of the previous page.
 func ListRecordings(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -162,12 +171,26 @@ func ListRecordings(c *gin.Context) {
 		return
 	}
 
-	rows, err := database.DB.Query(context.Background(),
-		`SELECT id, user_id, file_path, original_filename, duration, file_size, pitch_hz, created_at, updated_at
-		 FROM recordings
-		 WHERE user_id = $1
-		 ORDER BY created_at DESC`,
-		userID)
+	ctx := context.Background()
+	user, err := loadUser(ctx, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+		return
+	}
+
+	q, invalid := parseRecordingQuery(c, user.ID, gamification.LoadLocation(user.Timezone))
+	if invalid != "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   invalid,
+		})
+		return
+	}
+
+	rows, err := database.DB.Query(ctx, q.sql(), q.args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -180,18 +203,45 @@ func ListRecordings(c *gin.Context) {
 	recordings := []models.Recording{}
 	for rows.Next() {
 		var r models.Recording
-		err := rows.Scan(&r.ID, &r.UserID, &r.FilePath, &r.OriginalFilename,
-			&r.Duration, &r.FileSize, &r.PitchHz, &r.CreatedAt, &r.UpdatedAt)
-		if err != nil {
-			continue
+		if err := scanRecording(&r, rows); err != nil {
+			log.Printf("Failed to read recording: %v", err)
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to fetch recordings",
+			})
+			return
 		}
 		recordings = append(recordings, r)
 	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch recordings",
+		})
+		return
+	}
+
+	// The extra row only tells that another page follows
+	var nextCursor *string
+	if len(recordings) > q.limit {
+		recordings = recordings[:q.limit]
+		cursor := q.cursorAfter(&recordings[q.limit-1])
+		nextCursor = &cursor
+	}
+
+	if err := attachTags(ctx, recordings); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch recording tags",
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Data: gin.H{
-			"recordings": recordings,
+			"recordings":  recordings,
+			"next_cursor": nextCursor,
 		},
 	})
 }
@@ -210,14 +260,11 @@ func GetRecording(c *gin.Context) {
 	recordingID := c.Param("id")
 
 	var recording models.Recording
-	err := database.DB.QueryRow(context.Background(),
-		`SELECT id, user_id, file_path, original_filename, duration, file_size, pitch_hz, created_at, updated_at
+	err := scanRecording(&recording, database.DB.QueryRow(context.Background(),
+		`SELECT `+recordingColumns+`
 		 FROM recordings
 		 WHERE id = $1 AND user_id = $2`,
-		recordingID, userID).Scan(
-		&recording.ID, &recording.UserID, &recording.FilePath, &recording.OriginalFilename,
-		&recording.Duration, &recording.FileSize, &recording.PitchHz,
-		&recording.CreatedAt, &recording.UpdatedAt)
+		recordingID, userID))
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, models.APIResponse{
@@ -227,10 +274,143 @@ func GetRecording(c *gin.Context) {
 		return
 	}
 
+	segments, err := loadSegments(context.Background(), recording.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch voice activity segments",
+		})
+		return
+	}
+
+	recordings := []models.Recording{recording}
+	if err := attachTags(context.Background(), recordings); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch recording tags",
+		})
+		return
+	}
+	recording = recordings[0]
+
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Data: gin.H{
 			"recording": recording,
+			"segments":  segments,
+		},
+	})
+}
+
+// UpdateRecording changes the title, notes and tags of a recording
+func UpdateRecording(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	recordingID := c.Param("id")
+	if _, err := uuid.Parse(recordingID); err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Recording not found",
+		})
+		return
+	}
+
+	var req models.UpdateRecordingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	// An empty title or notes clears it; unset fields keep their value
+	var title, notes *string
+	if req.Title != nil {
+		if trimmed := strings.TrimSpace(*req.Title); trimmed != "" {
+			title = &trimmed
+		}
+	}
+	if req.Notes != nil {
+		if trimmed := strings.TrimSpace(*req.Notes); trimmed != "" {
+			notes = &trimmed
+		}
+	}
+
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update recording",
+		})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// updated_at also moves when only the tags change
+	var recording models.Recording
+	err = scanRecording(&recording, tx.QueryRow(ctx,
+		`UPDATE recordings
+		 SET title = CASE WHEN $1 THEN $2 ELSE title END,
+		     notes = CASE WHEN $3 THEN $4 ELSE notes END,
+		     updated_at = NOW()
+		 WHERE id = $5 AND user_id = $6
+		 RETURNING `+recordingColumns,
+		req.Title != nil, title, req.Notes != nil, notes, recordingID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Recording not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update recording",
+		})
+		return
+	}
+
+	if req.Tags != nil {
+		if err := setRecordingTags(ctx, tx, recording.UserID, recording.ID, normalizeTags(req.Tags)); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to update recording tags",
+			})
+			return
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update recording",
+		})
+		return
+	}
+
+	recordings := []models.Recording{recording}
+	if err := attachTags(ctx, recordings); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch recording tags",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"recording": recordings[0],
 		},
 	})
 }
@@ -249,10 +429,10 @@ func DeleteRecording(c *gin.Context) {
 	recordingID := c.Param("id")
 
 	// Get recording to delete file
-	var filePath string
+	var fileKey string
 	err := database.DB.QueryRow(context.Background(),
 		`SELECT file_path FROM recordings WHERE id = $1 AND user_id = $2`,
-		recordingID, userID).Scan(&filePath)
+		recordingID, userID).Scan(&fileKey)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, models.APIResponse{
@@ -275,8 +455,17 @@ func DeleteRecording(c *gin.Context) {
 		return
 	}
 
-	// Delete file from disk
-	os.Remove(filePath)
+	// Delete the upload and its processed artifacts from storage
+	for _, key := range []string{
+		fileKey,
+		audio.WAVKeyFor(fileKey),
+		audio.WaveformKeyFor(fileKey),
+		audio.SpectrogramKeyFor(fileKey),
+	} {
+		if err := storage.Default.Delete(context.Background(), key); err != nil {
+			log.Printf("Failed to delete %s: %v", key, err)
+		}
+	}
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
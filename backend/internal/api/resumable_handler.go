@@ -0,0 +1,616 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/models"
+	"voice-training-app/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	TusVersion             = "1.0.0"
+	MaxResumableUploadSize = 500 * 1024 * 1024 // 500MB
+	MaxUploadChunkSize     = 16 * 1024 * 1024  // Chunks are buffered in memory to verify their checksum
+	UploadChunkPrefix      = "upload-chunks"   // Storage key prefix of received chunks
+	DefaultUploadExpiry    = 24 * time.Hour    // Abandoned uploads are removed after this long idle
+
+	offsetOctetStream      = "application/offset+octet-stream"
+	statusChecksumMismatch = 460 // tus checksum extension
+)
+
+// uploadSessionColumns lists the upload_sessions columns read by scanUploadSession, in scan order
+const uploadSessionColumns = `id, user_id, filename, content_type, upload_length, upload_offset,
+	session_id, exercise_id, recording_id, completed_at, expires_at, created_at, updated_at`
+
+// scanUploadSession scans a row selected with uploadSessionColumns into u
+func scanUploadSession(u *models.UploadSession, row pgx.Row) error {
+	return row.Scan(&u.ID, &u.UserID, &u.Filename, &u.ContentType, &u.Length, &u.Offset,
+		&u.SessionID, &u.ExerciseID, &u.RecordingID, &u.CompletedAt, &u.ExpiresAt, &u.CreatedAt, &u.UpdatedAt)
+}
+
+// uploadExpiry reads UPLOAD_EXPIRY_HOURS, falling back to DefaultUploadExpiry
+func uploadExpiry() time.Duration {
+	if v, err := strconv.ParseFloat(os.Getenv("UPLOAD_EXPIRY_HOURS"), 64); err == nil && v > 0 {
+		return time.Duration(v * float64(time.Hour))
+	}
+	return DefaultUploadExpiry
+}
+
+// CreateResumableUpload starts a resumable upload. The total size comes from
+// the Upload-Length header and the filename, type and optional practice
+// session_id and exercise_id from Upload-Metadata.
+func CreateResumableUpload(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	c.Header("Tus-Resumable", TusVersion)
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Upload-Length must be a positive integer",
+		})
+		return
+	}
+	if length > MaxResumableUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("File size exceeds maximum allowed size of %dMB", MaxResumableUploadSize/(1024*1024)),
+		})
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid Upload-Metadata",
+		})
+		return
+	}
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = "recording"
+	}
+
+	// The practice session the recording is made in, linked once the upload completes
+	var sessionID *string
+	if id := metadata["session_id"]; id != "" {
+		if err := checkUploadSession(context.Background(), userID.(string), id); err != nil {
+			respondIngestError(c, err)
+			return
+		}
+		sessionID = &id
+	}
+
+	// The exercise the recording is made for
+	var exerciseID *string
+	if id := metadata["exercise_id"]; id != "" {
+		if err := checkUploadExercise(context.Background(), id); err != nil {
+			respondIngestError(c, err)
+			return
+		}
+		exerciseID = &id
+	}
+
+	var upload models.UploadSession
+	err = scanUploadSession(&upload, database.DB.QueryRow(context.Background(),
+		`INSERT INTO upload_sessions (user_id, filename, content_type, upload_length, session_id, exercise_id, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING `+uploadSessionColumns,
+		userID, filename, metadata["filetype"], length, sessionID, exerciseID, time.Now().Add(uploadExpiry())))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create upload",
+		})
+		return
+	}
+
+	c.Header("Location", "/api/v1/recordings/uploads/"+upload.ID)
+	setUploadHeaders(c, &upload)
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"upload": upload,
+		},
+	})
+}
+
+// GetResumableUploadStatus reports how much of an upload the server has, so
+// the client knows where to resume
+func GetResumableUploadStatus(c *gin.Context) {
+	c.Header("Tus-Resumable", TusVersion)
+
+	upload, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	setUploadHeaders(c, upload)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// PatchResumableUpload appends a chunk at Upload-Offset. A chunk carrying an
+// Upload-Checksum header is verified before it is kept. The chunk that
+// completes the upload assembles the file and creates its recording; an empty
+// PATCH at the final offset retries that step.
+func PatchResumableUpload(c *gin.Context) {
+	c.Header("Tus-Resumable", TusVersion)
+
+	if c.ContentType() != offsetOctetStream {
+		c.JSON(http.StatusUnsupportedMediaType, models.APIResponse{
+			Success: false,
+			Error:   "Content-Type must be " + offsetOctetStream,
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Upload-Offset must be a non-negative integer",
+		})
+		return
+	}
+
+	checksum, err := parseUploadChecksum(c.GetHeader("Upload-Checksum"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	upload, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+	if upload.CompletedAt != nil {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "Upload is already complete",
+		})
+		return
+	}
+	if offset != upload.Offset {
+		c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Upload-Offset %d does not match the current offset %d", offset, upload.Offset),
+		})
+		return
+	}
+
+	// Buffer the chunk so its checksum can be verified before it is stored
+	remaining := upload.Length - upload.Offset
+	limit := min(remaining, MaxUploadChunkSize)
+	chunk, err := io.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Failed to read chunk",
+		})
+		return
+	}
+	if int64(len(chunk)) > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Chunk exceeds the remaining %d bytes or the %dMB chunk limit", remaining, MaxUploadChunkSize/(1024*1024)),
+		})
+		return
+	}
+	if checksum != nil {
+		checksum.hash.Write(chunk)
+		if !bytes.Equal(checksum.hash.Sum(nil), checksum.sum) {
+			c.JSON(statusChecksumMismatch, models.APIResponse{
+				Success: false,
+				Error:   "Checksum mismatch",
+				Code:    "checksum_mismatch",
+			})
+			return
+		}
+	}
+
+	if len(chunk) > 0 {
+		upload, err = appendUploadChunk(c.Request.Context(), upload, chunk)
+		if errors.Is(err, errUploadOffsetMoved) {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   "Upload offset changed while the chunk was being received",
+			})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to store chunk of upload %s: %v", upload.ID, err)
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to store chunk",
+			})
+			return
+		}
+	}
+
+	setUploadHeaders(c, upload)
+	if upload.Offset < upload.Length {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	recording, err := completeUpload(context.Background(), upload)
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"recording": recording,
+		},
+	})
+}
+
+// DeleteResumableUpload abandons an upload and discards its chunks
+func DeleteResumableUpload(c *gin.Context) {
+	c.Header("Tus-Resumable", TusVersion)
+
+	upload, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	if err := removeUploadSession(context.Background(), upload.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete upload",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ExpireUploadSessions removes uploads idle past their expiry along with their chunks
+func ExpireUploadSessions(ctx context.Context) (int, error) {
+	rows, err := database.DB.Query(ctx,
+		`SELECT id FROM upload_sessions WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, id := range ids {
+		if err := removeUploadSession(ctx, id); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// ReapExpiredUploads calls ExpireUploadSessions every interval until ctx is cancelled
+func ReapExpiredUploads(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := ExpireUploadSessions(ctx); err != nil {
+				log.Printf("Failed to expire abandoned uploads: %v", err)
+			} else if n > 0 {
+				log.Printf("Expired %d abandoned uploads", n)
+			}
+		}
+	}
+}
+
+// loadUploadSession loads the caller's unexpired upload named by the :id path
+// parameter. It writes the error response itself and returns false when the
+// handler should stop.
+func loadUploadSession(c *gin.Context) (*models.UploadSession, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return nil, false
+	}
+
+	var upload models.UploadSession
+	err := scanUploadSession(&upload, database.DB.QueryRow(context.Background(),
+		`SELECT `+uploadSessionColumns+`
+		 FROM upload_sessions
+		 WHERE id = $1 AND user_id = $2 AND expires_at > NOW()`,
+		c.Param("id"), userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Upload not found",
+		})
+		return nil, false
+	}
+
+	return &upload, true
+}
+
+func setUploadHeaders(c *gin.Context, upload *models.UploadSession) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+var errUploadOffsetMoved = errors.New("upload offset moved")
+
+// appendUploadChunk stores chunk at the upload's current offset and advances
+// it. The session row stays locked while the chunk is written so concurrent
+// PATCHes cannot interleave.
+func appendUploadChunk(ctx context.Context, upload *models.UploadSession, chunk []byte) (*models.UploadSession, error) {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return upload, err
+	}
+	defer tx.Rollback(ctx)
+
+	var offset int64
+	err = tx.QueryRow(ctx,
+		`SELECT upload_offset FROM upload_sessions WHERE id = $1 AND completed_at IS NULL FOR UPDATE`,
+		upload.ID).Scan(&offset)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && offset != upload.Offset) {
+		return upload, errUploadOffsetMoved
+	}
+	if err != nil {
+		return upload, err
+	}
+
+	key := uploadChunkKey(upload.ID, offset)
+	if err := storage.Default.Put(ctx, key, bytes.NewReader(chunk), int64(len(chunk)), offsetOctetStream); err != nil {
+		return upload, err
+	}
+
+	var updated models.UploadSession
+	err = scanUploadSession(&updated, tx.QueryRow(ctx,
+		`UPDATE upload_sessions
+		 SET upload_offset = $1, expires_at = $2, updated_at = NOW()
+		 WHERE id = $3
+		 RETURNING `+uploadSessionColumns,
+		offset+int64(len(chunk)), time.Now().Add(uploadExpiry()), upload.ID))
+	if err != nil {
+		return upload, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return upload, err
+	}
+	return &updated, nil
+}
+
+// completeUpload assembles the chunks of a fully received upload and hands the
+// file to the regular ingest path. Uploads that fail validation are discarded.
+func completeUpload(ctx context.Context, upload *models.UploadSession) (*models.Recording, error) {
+	assembled, err := assembleUpload(ctx, upload)
+	if err != nil {
+		return nil, &ingestError{status: http.StatusInternalServerError, message: "Failed to assemble upload", err: err}
+	}
+	defer os.Remove(assembled)
+
+	recording, err := ingestRecording(ctx, upload.UserID, assembled, upload.Filename, upload.ContentType,
+		func(ctx context.Context, tx pgx.Tx, recording *models.Recording) error {
+			tag, err := tx.Exec(ctx,
+				`UPDATE upload_sessions
+				 SET recording_id = $1, completed_at = NOW(), updated_at = NOW()
+				 WHERE id = $2 AND completed_at IS NULL`,
+				recording.ID, upload.ID)
+			if err != nil {
+				return err
+			}
+			if tag.RowsAffected() == 0 {
+				return &ingestError{status: http.StatusConflict, message: "Upload is already complete"}
+			}
+			if upload.ExerciseID != nil {
+				if err := linkToExercise(ctx, tx, *upload.ExerciseID, recording); err != nil {
+					return err
+				}
+			}
+			if upload.SessionID != nil {
+				return linkToSession(ctx, tx, *upload.SessionID, recording)
+			}
+			return nil
+		})
+
+	var ingestErr *ingestError
+	if errors.As(err, &ingestErr) && ingestErr.status < http.StatusInternalServerError && ingestErr.status != http.StatusConflict {
+		// The content was rejected; resending the same bytes cannot help
+		if err := removeUploadSession(ctx, upload.ID); err != nil {
+			log.Printf("Failed to discard rejected upload %s: %v", upload.ID, err)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The recording owns a copy now; the chunks are no longer needed
+	if err := deleteUploadChunks(ctx, upload.ID); err != nil {
+		log.Printf("Failed to delete chunks of upload %s: %v", upload.ID, err)
+	}
+
+	return recording, nil
+}
+
+// assembleUpload concatenates the stored chunks of upload into a temporary file
+func assembleUpload(ctx context.Context, upload *models.UploadSession) (string, error) {
+	chunks, err := storage.Default.List(ctx, uploadChunkPrefix(upload.ID))
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Key < chunks[j].Key })
+
+	file, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return "", err
+	}
+
+	var written int64
+	for _, chunk := range chunks {
+		offset, err := strconv.ParseInt(path.Base(chunk.Key), 10, 64)
+		if err != nil || offset != written {
+			continue // Leftover from an interrupted PATCH that never advanced the offset
+		}
+
+		if err := copyObject(ctx, file, chunk.Key); err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return "", err
+		}
+		written += chunk.Size
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	if written != upload.Length {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("assembled %d of %d bytes", written, upload.Length)
+	}
+
+	return file.Name(), nil
+}
+
+func copyObject(ctx context.Context, dst io.Writer, key string) error {
+	obj, err := storage.Default.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	_, err = io.Copy(dst, obj)
+	return err
+}
+
+// removeUploadSession deletes the chunks of an upload and then its row
+func removeUploadSession(ctx context.Context, uploadID string) error {
+	if err := deleteUploadChunks(ctx, uploadID); err != nil {
+		return err
+	}
+
+	_, err := database.DB.Exec(ctx, `DELETE FROM upload_sessions WHERE id = $1`, uploadID)
+	return err
+}
+
+func deleteUploadChunks(ctx context.Context, uploadID string) error {
+	chunks, err := storage.Default.List(ctx, uploadChunkPrefix(uploadID))
+	if err != nil {
+		return err
+	}
+
+	for _, chunk := range chunks {
+		if err := storage.Default.Delete(ctx, chunk.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func uploadChunkPrefix(uploadID string) string {
+	return UploadChunkPrefix + "/" + uploadID + "/"
+}
+
+// uploadChunkKey zero-pads the offset so chunk keys sort in upload order
+func uploadChunkKey(uploadID string, offset int64) string {
+	return fmt.Sprintf("%s%020d", uploadChunkPrefix(uploadID), offset)
+}
+
+// parseUploadMetadata decodes a tus Upload-Metadata header: comma-separated
+// pairs of a key and a base64 value
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// uploadChecksum is an expected chunk digest from an Upload-Checksum header
+type uploadChecksum struct {
+	hash hash.Hash
+	sum  []byte
+}
+
+// parseUploadChecksum decodes "<algorithm> <base64 digest>"; an empty header means no checksum
+func parseUploadChecksum(header string) (*uploadChecksum, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	algorithm, encoded, _ := strings.Cut(strings.TrimSpace(header), " ")
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("Upload-Checksum digest must be base64")
+	}
+
+	var h hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "sha256":
+		h = sha256.New()
+	case "sha1":
+		h = sha1.New()
+	case "md5":
+		h = md5.New()
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q (use sha256, sha1 or md5)", algorithm)
+	}
+
+	return &uploadChecksum{hash: h, sum: sum}, nil
+}
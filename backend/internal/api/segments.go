@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"voice-training-app/internal/audio"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// saveSegments replaces the stored voice activity segments of a recording
+func saveSegments(ctx context.Context, tx pgx.Tx, recordingID string, segments []audio.Segment) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM recording_segments WHERE recording_id = $1`, recordingID); err != nil {
+		return err
+	}
+
+	_, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"recording_segments"},
+		[]string{"recording_id", "segment_index", "start_sec", "end_sec", "kind"},
+		pgx.CopyFromSlice(len(segments), func(i int) ([]any, error) {
+			seg := segments[i]
+			return []any{recordingID, i, seg.Start, seg.End, string(seg.Kind)}, nil
+		}))
+
+	return err
+}
+
+// loadSegments returns the voice activity segments of a recording in order
+func loadSegments(ctx context.Context, recordingID string) ([]models.Segment, error) {
+	rows, err := database.DB.Query(ctx,
+		`SELECT start_sec, end_sec, kind
+		 FROM recording_segments
+		 WHERE recording_id = $1
+		 ORDER BY segment_index`,
+		recordingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	segments := []models.Segment{}
+	for rows.Next() {
+		var seg models.Segment
+		if err := rows.Scan(&seg.Start, &seg.End, &seg.Kind); err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+
+	return segments, rows.Err()
+}
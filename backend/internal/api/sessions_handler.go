@@ -0,0 +1,534 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"voice-training-app/internal/achievements"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/gamification"
+	"voice-training-app/internal/jobs"
+	"voice-training-app/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// SessionIdleTimeout is the longest gap between heartbeats that still counts
+// as practice. Clients should send a heartbeat well within it, e.g. every 30s.
+const SessionIdleTimeout = 2 * time.Minute
+
+// sessionColumns lists the sessions columns read by scanSession, in scan order
+const sessionColumns = `id, user_id, status, duration, active_seconds, exercises_completed, xp_earned,
+	last_heartbeat_at, completed_at, created_at, updated_at`
+
+// scanSession scans a row selected with sessionColumns into s
+func scanSession(s *models.PracticeSession, row pgx.Row) error {
+	return row.Scan(&s.ID, &s.UserID, &s.Status, &s.Duration, &s.ActiveSeconds,
+		&s.ExercisesCompleted, &s.XPEarned,
+		&s.LastHeartbeatAt, &s.CompletedAt, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// sessionGapSQL is the practice time since the last heartbeat, or zero when
+// the gap was long enough that the user had stopped
+var sessionGapSQL = fmt.Sprintf(`(CASE
+	WHEN NOW() - last_heartbeat_at <= INTERVAL '%d seconds' THEN EXTRACT(EPOCH FROM NOW() - last_heartbeat_at)
+	ELSE 0 END)`, int(SessionIdleTimeout/time.Second))
+
+// sessionExercisesSQL counts the distinct exercises a session has recordings
+// of that were not rejected by processing; free recordings do not count
+const sessionExercisesSQL = `(SELECT COUNT(DISTINCT r.exercise_id) FROM recordings r
+	WHERE r.session_id = sessions.id AND r.status <> 'failed')`
+
+// completeSessionSQL closes the matching active sessions, computing their
+// duration and exercise count from what the server has seen
+var completeSessionSQL = `UPDATE sessions
+	SET status = 'completed',
+	    active_seconds = active_seconds + ` + sessionGapSQL + `,
+	    duration = ROUND(active_seconds + ` + sessionGapSQL + `)::int,
+	    exercises_completed = ` + sessionExercisesSQL + `,
+	    last_heartbeat_at = NOW(), completed_at = NOW(), updated_at = NOW()`
+
+// sessionXPSQL sums the XP earned by completing a session and by the recordings made in it
+const sessionXPSQL = `(SELECT COALESCE(SUM(e.xp), 0) FROM xp_events e
+	WHERE (e.source = '` + gamification.SourceSession + `' AND e.source_id = sessions.id::text)
+	   OR (e.source = '` + gamification.SourceRecording + `' AND e.source_id IN
+	       (SELECT r.id::text FROM recordings r WHERE r.session_id = sessions.id)))`
+
+// completeSessions closes the active sessions matched by where and awards
+// their XP. Running it again for the same sessions awards nothing.
+func completeSessions(ctx context.Context, tx pgx.Tx, where string, args ...any) ([]models.PracticeSession, []*gamification.Outcome, error) {
+	rows, err := tx.Query(ctx, completeSessionSQL+` `+where+` RETURNING `+sessionColumns, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	sessions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.PracticeSession, error) {
+		var s models.PracticeSession
+		err := scanSession(&s, row)
+		return s, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outcomes := make([]*gamification.Outcome, len(sessions))
+	for i := range sessions {
+		s := &sessions[i]
+		outcomes[i], err = gamification.Award(ctx, tx, gamification.Event{
+			UserID:     s.UserID,
+			Source:     gamification.SourceSession,
+			SourceID:   s.ID,
+			XP:         gamification.SessionXP(*s.Duration, s.ExercisesCompleted),
+			OccurredAt: *s.CompletedAt,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = tx.QueryRow(ctx,
+			`UPDATE sessions SET xp_earned = `+sessionXPSQL+` WHERE id = $1 RETURNING xp_earned`,
+			s.ID).Scan(&s.XPEarned)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return sessions, outcomes, nil
+}
+
+// refreshSessionXP recomputes the XP earned in a session after one of its recordings earned XP
+func refreshSessionXP(ctx context.Context, db jobs.Execer, sessionID string) error {
+	_, err := db.Exec(ctx,
+		`UPDATE sessions SET xp_earned = `+sessionXPSQL+`, updated_at = NOW() WHERE id = $1`,
+		sessionID)
+	return err
+}
+
+// StartSession opens a new practice session. A session the user left open is
+// completed first, so there is at most one active session per user.
+func StartSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	ctx := context.Background()
+	session, err := startSession(ctx, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to start session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"session": session,
+		},
+	})
+}
+
+func startSession(ctx context.Context, userID string) (*models.PracticeSession, error) {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize session starts of the same user
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, err
+	}
+
+	if _, _, err := completeSessions(ctx, tx, `WHERE user_id = $1 AND status = 'active'`, userID); err != nil {
+		return nil, err
+	}
+
+	var session models.PracticeSession
+	err = scanSession(&session, tx.QueryRow(ctx,
+		`INSERT INTO sessions (user_id) VALUES ($1) RETURNING `+sessionColumns, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := achievements.Evaluate(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// SessionHeartbeat records that the user is still practicing
+func SessionHeartbeat(c *gin.Context) {
+	session, ok := loadActiveSession(c)
+	if !ok {
+		return
+	}
+
+	err := scanSession(session, database.DB.QueryRow(context.Background(),
+		`UPDATE sessions
+		 SET active_seconds = active_seconds + `+sessionGapSQL+`,
+		     last_heartbeat_at = NOW(), updated_at = NOW()
+		 WHERE id = $1 AND status = 'active'
+		 RETURNING `+sessionColumns,
+		session.ID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondSessionComplete(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to record heartbeat",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"session": session,
+		},
+	})
+}
+
+// CompleteSession closes a session and awards its XP. Its duration is the
+// heartbeat-covered practice time and its exercise count the distinct
+// exercises recorded in it; neither is taken from the client.
+func CompleteSession(c *gin.Context) {
+	session, ok := loadActiveSession(c)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to complete session",
+		})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var unlocked []achievements.Achievement
+	sessions, outcomes, err := completeSessions(ctx, tx, `WHERE id = $1 AND status = 'active'`, session.ID)
+	if err == nil && len(sessions) == 1 {
+		unlocked, err = achievements.Evaluate(ctx, tx, session.UserID)
+	}
+	if err == nil && len(sessions) == 1 {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		log.Printf("Failed to complete session %s: %v", session.ID, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to complete session",
+		})
+		return
+	}
+	if len(sessions) == 0 {
+		respondSessionComplete(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"session":      sessions[0],
+			"reward":       outcomes[0], // null when the session earned no XP
+			"achievements": unlocked,    // Unlocked by this session
+		},
+	})
+}
+
+// AttachSessionRecording links an existing recording to an active session
+func AttachSessionRecording(c *gin.Context) {
+	var req models.AttachRecordingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	session, ok := loadActiveSession(c)
+	if !ok {
+		return
+	}
+
+	var recording models.Recording
+	err := scanRecording(&recording, database.DB.QueryRow(context.Background(),
+		`UPDATE recordings
+		 SET session_id = $1, updated_at = NOW()
+		 WHERE id = $2 AND user_id = $3 AND (session_id IS NULL OR session_id = $1)
+		 RETURNING `+recordingColumns,
+		session.ID, req.RecordingID, session.UserID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Tell a recording of another session apart from a missing one
+		var inOtherSession bool
+		database.DB.QueryRow(context.Background(),
+			`SELECT EXISTS(SELECT 1 FROM recordings WHERE id = $1 AND user_id = $2)`,
+			req.RecordingID, session.UserID).Scan(&inOtherSession)
+		if inOtherSession {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   "Recording belongs to another session",
+			})
+			return
+		}
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Recording not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to attach recording",
+		})
+		return
+	}
+
+	if err := refreshSessionXP(context.Background(), database.DB, session.ID); err != nil {
+		log.Printf("Failed to update XP of session %s: %v", session.ID, err)
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"recording": recording,
+		},
+	})
+}
+
+// ListSessions returns the session history of the authenticated user, newest
+// first, optionally filtered by ?status=
+func ListSessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	var status *string
+	if raw := c.Query("status"); raw != "" {
+		if raw != models.SessionStatusActive && raw != models.SessionStatusCompleted {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid status filter",
+			})
+			return
+		}
+		status = &raw
+	}
+
+	rows, err := database.DB.Query(context.Background(),
+		`SELECT `+sessionColumns+`
+		 FROM sessions
+		 WHERE user_id = $1 AND ($2::text IS NULL OR status = $2)
+		 ORDER BY created_at DESC`,
+		userID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch sessions",
+		})
+		return
+	}
+
+	sessions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.PracticeSession, error) {
+		var s models.PracticeSession
+		err := scanSession(&s, row)
+		return s, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"sessions": sessions,
+		},
+	})
+}
+
+// GetSession returns a session with the recordings made in it
+func GetSession(c *gin.Context) {
+	session, ok := loadSession(c)
+	if !ok {
+		return
+	}
+
+	rows, err := database.DB.Query(context.Background(),
+		`SELECT `+recordingColumns+`
+		 FROM recordings
+		 WHERE session_id = $1
+		 ORDER BY created_at`,
+		session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch session recordings",
+		})
+		return
+	}
+
+	recordings, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Recording, error) {
+		var r models.Recording
+		err := scanRecording(&r, row)
+		return r, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch session recordings",
+		})
+		return
+	}
+
+	if err := attachTags(context.Background(), recordings); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch recording tags",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"session":    session,
+			"recordings": recordings,
+		},
+	})
+}
+
+// loadSession loads the caller's session named by the :id path parameter. It
+// writes the error response itself and returns false when the handler should stop.
+func loadSession(c *gin.Context) (*models.PracticeSession, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return nil, false
+	}
+
+	session, err := querySession(context.Background(), c.Param("id"), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Session not found",
+		})
+		return nil, false
+	}
+
+	return session, true
+}
+
+// loadActiveSession is loadSession for handlers that need the session open
+func loadActiveSession(c *gin.Context) (*models.PracticeSession, bool) {
+	session, ok := loadSession(c)
+	if !ok {
+		return nil, false
+	}
+	if session.Status != models.SessionStatusActive {
+		respondSessionComplete(c)
+		return nil, false
+	}
+	return session, true
+}
+
+func respondSessionComplete(c *gin.Context) {
+	c.JSON(http.StatusConflict, models.APIResponse{
+		Success: false,
+		Error:   "Session is already complete",
+	})
+}
+
+func querySession(ctx context.Context, sessionID, userID string) (*models.PracticeSession, error) {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return nil, pgx.ErrNoRows
+	}
+
+	var session models.PracticeSession
+	err := scanSession(&session, database.DB.QueryRow(ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE id = $1 AND user_id = $2`,
+		sessionID, userID))
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// checkUploadSession checks that an upload may be linked to sessionID, which
+// must name an active session of the user. Failures are returned as *ingestError.
+func checkUploadSession(ctx context.Context, userID, sessionID string) error {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return &ingestError{status: http.StatusBadRequest, message: "Invalid session_id"}
+	}
+
+	session, err := querySession(ctx, sessionID, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &ingestError{status: http.StatusNotFound, message: "Session not found"}
+	}
+	if err != nil {
+		return &ingestError{status: http.StatusInternalServerError, message: "Failed to load session", err: err}
+	}
+	if session.Status != models.SessionStatusActive {
+		return &ingestError{status: http.StatusConflict, message: "Session is already complete"}
+	}
+	return nil
+}
+
+// linkToSession links a recording to the session it was made in. An upload
+// that finishes after its session was completed still counts towards it.
+func linkToSession(ctx context.Context, tx pgx.Tx, sessionID string, recording *models.Recording) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE recordings SET session_id = $1 WHERE id = $2`,
+		sessionID, recording.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE sessions SET exercises_completed = `+sessionExercisesSQL+`, updated_at = NOW()
+		 WHERE id = $1 AND status = 'completed'`,
+		sessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := refreshSessionXP(ctx, tx, sessionID); err != nil {
+		return err
+	}
+
+	recording.SessionID = &sessionID
+	return nil
+}
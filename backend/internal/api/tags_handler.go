@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// normalizeTags trims and lowercases tag names, dropping blanks and
+// duplicates, and returns them sorted
+func normalizeTags(names []string) []string {
+	tags := []string{}
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			tags = append(tags, name)
+		}
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// setRecordingTags replaces the tags of a recording with names, creating the
+// user's tags that do not exist yet and dropping those left unused
+func setRecordingTags(ctx context.Context, tx pgx.Tx, userID, recordingID string, names []string) error {
+	// Serialize tag changes of the same user, so the cleanup below cannot
+	// drop a tag another recording is being given concurrently
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx,
+		`INSERT INTO tags (user_id, name)
+		 SELECT $1, unnest($2::text[])
+		 ON CONFLICT (user_id, name) DO NOTHING`,
+		userID, names)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM recording_tags WHERE recording_id = $1`, recordingID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO recording_tags (recording_id, tag_id)
+		 SELECT $1, id FROM tags WHERE user_id = $2 AND name = ANY($3::text[])`,
+		recordingID, userID, names)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		`DELETE FROM tags t
+		 WHERE t.user_id = $1 AND NOT EXISTS (SELECT 1 FROM recording_tags rt WHERE rt.tag_id = t.id)`,
+		userID)
+	return err
+}
+
+// attachTags loads the tags of each recording into its Tags
+func attachTags(ctx context.Context, recordings []models.Recording) error {
+	if len(recordings) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(recordings))
+	for i, r := range recordings {
+		ids[i] = r.ID
+	}
+
+	rows, err := database.DB.Query(ctx,
+		`SELECT rt.recording_id, t.name
+		 FROM recording_tags rt JOIN tags t ON t.id = rt.tag_id
+		 WHERE rt.recording_id = ANY($1::uuid[])
+		 ORDER BY t.name`,
+		ids)
+	if err != nil {
+		return err
+	}
+
+	tags := map[string][]string{}
+	var recordingID, name string
+	_, err = pgx.ForEachRow(rows, []any{&recordingID, &name}, func() error {
+		tags[recordingID] = append(tags[recordingID], name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range recordings {
+		recordings[i].Tags = tags[recordings[i].ID]
+	}
+	return nil
+}
+
+// ListRecordingTags returns the tags of the authenticated user's recordings
+// with the number of recordings carrying each
+func ListRecordingTags(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	rows, err := database.DB.Query(context.Background(),
+		`SELECT t.name, COUNT(*)
+		 FROM tags t JOIN recording_tags rt ON rt.tag_id = t.id
+		 WHERE t.user_id = $1
+		 GROUP BY t.name
+		 ORDER BY t.name`,
+		userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch tags",
+		})
+		return
+	}
+
+	tags, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Tag, error) {
+		var t models.Tag
+		err := row.Scan(&t.Name, &t.Recordings)
+		return t, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch tags",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"tags": tags,
+		},
+	})
+}
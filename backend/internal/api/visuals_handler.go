@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"voice-training-app/internal/audio"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/models"
+	"voice-training-app/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetWaveform returns the cached waveform peaks of a processed recording
+func GetWaveform(c *gin.Context) {
+	recording, ok := loadProcessedRecording(c)
+	if !ok {
+		return
+	}
+
+	data, err := readObject(c.Request.Context(), audio.WaveformKeyFor(recording.FilePath))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Waveform not available",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"waveform": json.RawMessage(data),
+		},
+	})
+}
+
+// GetSpectrogram serves the cached spectrogram PNG of a processed recording
+func GetSpectrogram(c *gin.Context) {
+	recording, ok := loadProcessedRecording(c)
+	if !ok {
+		return
+	}
+
+	obj, err := storage.Default.Get(c.Request.Context(), audio.SpectrogramKeyFor(recording.FilePath))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Spectrogram not available",
+		})
+		return
+	}
+	defer obj.Close()
+
+	c.Header("Content-Type", "image/png")
+	c.Header("Cache-Control", "private, max-age=86400")
+	http.ServeContent(c.Writer, c.Request, "spectrogram.png", obj.Info().ModTime, obj)
+}
+
+// readObject reads the whole object stored under key
+func readObject(ctx context.Context, key string) ([]byte, error) {
+	obj, err := storage.Default.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	return io.ReadAll(obj)
+}
+
+// loadOwnedRecording loads the caller's recording named by the :id path
+// parameter. It writes the error response itself and returns false when the
+// handler should stop.
+func loadOwnedRecording(c *gin.Context) (*models.Recording, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return nil, false
+	}
+
+	var recording models.Recording
+	err := scanRecording(&recording, database.DB.QueryRow(context.Background(),
+		`SELECT `+recordingColumns+`
+		 FROM recordings
+		 WHERE id = $1 AND user_id = $2`,
+		c.Param("id"), userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Recording not found",
+		})
+		return nil, false
+	}
+
+	return &recording, true
+}
+
+// loadProcessedRecording is loadOwnedRecording that also requires processing
+// to have finished
+func loadProcessedRecording(c *gin.Context) (*models.Recording, bool) {
+	recording, ok := loadOwnedRecording(c)
+	if !ok {
+		return nil, false
+	}
+
+	if recording.Status != models.RecordingStatusDone {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "Recording has not been processed yet (status: " + recording.Status + ")",
+		})
+		return nil, false
+	}
+
+	return recording, true
+}
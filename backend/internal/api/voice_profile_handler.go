@@ -0,0 +1,290 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"voice-training-app/internal/audio"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	MinBaselineVoicedSeconds = 3.0 // Voiced time a baseline needs across its recordings
+	MaxFormantHz             = audio.FormantTargetRate / 2
+)
+
+// voiceProfileColumns lists the voice_profiles columns read by scanVoiceProfile, in scan order
+const voiceProfileColumns = `user_id, goal, baseline_min_hz, baseline_median_hz, baseline_max_hz,
+	baseline_f1_hz, baseline_f2_hz, baseline_recording_ids, baseline_measured_at,
+	target_pitch_min_hz, target_pitch_max_hz, target_f1_min_hz, target_f1_max_hz,
+	target_f2_min_hz, target_f2_max_hz, created_at, updated_at`
+
+// scanVoiceProfile scans a row selected with voiceProfileColumns into p
+func scanVoiceProfile(p *models.VoiceProfile, row pgx.Row) error {
+	return row.Scan(&p.UserID, &p.Goal, &p.BaselineMinHz, &p.BaselineMedianHz, &p.BaselineMaxHz,
+		&p.BaselineF1Hz, &p.BaselineF2Hz, &p.BaselineRecordingIDs, &p.BaselineMeasuredAt,
+		&p.TargetPitchMinHz, &p.TargetPitchMaxHz, &p.TargetF1MinHz, &p.TargetF1MaxHz,
+		&p.TargetF2MinHz, &p.TargetF2MaxHz, &p.CreatedAt, &p.UpdatedAt)
+}
+
+// loadVoiceProfile loads the voice profile of a user, or an empty one when
+// the user has not set one up
+func loadVoiceProfile(ctx context.Context, userID string) (*models.VoiceProfile, error) {
+	var profile models.VoiceProfile
+	err := scanVoiceProfile(&profile, database.DB.QueryRow(ctx,
+		`SELECT `+voiceProfileColumns+` FROM voice_profiles WHERE user_id = $1`, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &models.VoiceProfile{UserID: userID, BaselineRecordingIDs: []string{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// pitchBand returns the band bounded by minHz and maxHz, or nil unless both are set
+func pitchBand(minHz, maxHz *float64) *audio.PitchRange {
+	if minHz == nil || maxHz == nil {
+		return nil
+	}
+	return &audio.PitchRange{MinHz: *minHz, MaxHz: *maxHz}
+}
+
+// voicePitchRange returns the range the pitch tracker searches for the voice
+// described by profile: its baseline and target bands with headroom, or the
+// default range when neither is known
+func voicePitchRange(profile *models.VoiceProfile) audio.PitchRange {
+	var bands []audio.PitchRange
+	for _, b := range []*audio.PitchRange{
+		pitchBand(profile.BaselineMinHz, profile.BaselineMaxHz),
+		pitchBand(profile.TargetPitchMinHz, profile.TargetPitchMaxHz),
+	} {
+		if b != nil {
+			bands = append(bands, *b)
+		}
+	}
+	return audio.SearchRange(bands...)
+}
+
+// checkBand validates a band given as optional bounds, which must be set
+// together and lie within [lo, hi]. It returns a message describing the
+// problem, or "" when the band is valid or unset.
+func checkBand(name string, minHz, maxHz *float64, lo, hi float64) string {
+	switch {
+	case (minHz == nil) != (maxHz == nil):
+		return fmt.Sprintf("%s_min_hz and %s_max_hz must be set together", name, name)
+	case minHz != nil && (*minHz < lo || *maxHz > hi || *minHz >= *maxHz):
+		return fmt.Sprintf("%s range must lie within %.0f-%.0f Hz", name, lo, hi)
+	}
+	return ""
+}
+
+// GetVoiceProfile returns the authenticated user's voice profile and the
+// pitch range their recordings are analyzed in
+func GetVoiceProfile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	respondVoiceProfile(c, userID.(string))
+}
+
+// UpdateVoiceProfile sets the goal and target bands of the authenticated
+// user's voice profile, creating it on first use
+func UpdateVoiceProfile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	var req models.UpdateVoiceProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	var invalid string
+	if req.Goal != nil && !models.ValidVoiceGoal(*req.Goal) {
+		invalid = "goal must be one of deepen, raise, range_extend, resonance"
+	}
+	for _, msg := range []string{
+		checkBand("target_pitch", req.TargetPitchMinHz, req.TargetPitchMaxHz, audio.MinPitchHz, audio.MaxPitchHz),
+		checkBand("target_f1", req.TargetF1MinHz, req.TargetF1MaxHz, audio.FormantMinHz, MaxFormantHz),
+		checkBand("target_f2", req.TargetF2MinHz, req.TargetF2MaxHz, audio.FormantMinHz, MaxFormantHz),
+	} {
+		if invalid == "" {
+			invalid = msg
+		}
+	}
+	if invalid != "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   invalid,
+		})
+		return
+	}
+
+	// Unset fields keep their current value
+	_, err := database.DB.Exec(context.Background(),
+		`INSERT INTO voice_profiles (user_id, goal, target_pitch_min_hz, target_pitch_max_hz,
+		                             target_f1_min_hz, target_f1_max_hz, target_f2_min_hz, target_f2_max_hz)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 ON CONFLICT (user_id) DO UPDATE
+		 SET goal = COALESCE(EXCLUDED.goal, voice_profiles.goal),
+		     target_pitch_min_hz = COALESCE(EXCLUDED.target_pitch_min_hz, voice_profiles.target_pitch_min_hz),
+		     target_pitch_max_hz = COALESCE(EXCLUDED.target_pitch_max_hz, voice_profiles.target_pitch_max_hz),
+		     target_f1_min_hz = COALESCE(EXCLUDED.target_f1_min_hz, voice_profiles.target_f1_min_hz),
+		     target_f1_max_hz = COALESCE(EXCLUDED.target_f1_max_hz, voice_profiles.target_f1_max_hz),
+		     target_f2_min_hz = COALESCE(EXCLUDED.target_f2_min_hz, voice_profiles.target_f2_min_hz),
+		     target_f2_max_hz = COALESCE(EXCLUDED.target_f2_max_hz, voice_profiles.target_f2_max_hz),
+		     updated_at = NOW()`,
+		userID, req.Goal, req.TargetPitchMinHz, req.TargetPitchMaxHz,
+		req.TargetF1MinHz, req.TargetF1MaxHz, req.TargetF2MinHz, req.TargetF2MaxHz)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update voice profile",
+		})
+		return
+	}
+
+	respondVoiceProfile(c, userID.(string))
+}
+
+// MeasureVoiceBaseline measures the authenticated user's baseline range from
+// a set of their processed onboarding recordings and stores it in their
+// voice profile, replacing any earlier baseline
+func MeasureVoiceBaseline(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	var req models.VoiceBaselineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request: " + err.Error(),
+		})
+		return
+	}
+	slices.Sort(req.RecordingIDs)
+	recordingIDs := slices.Compact(req.RecordingIDs)
+
+	ctx := context.Background()
+	var processed int
+	err := database.DB.QueryRow(ctx,
+		`SELECT COUNT(*) FROM recordings WHERE id = ANY($1::uuid[]) AND user_id = $2 AND status = $3`,
+		recordingIDs, userID, models.RecordingStatusDone).Scan(&processed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to load recordings",
+		})
+		return
+	}
+	if processed != len(recordingIDs) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Every baseline recording must be one of your processed recordings",
+			Code:    "recording_not_processed",
+		})
+		return
+	}
+
+	// Percentiles over the pooled voiced frames, so longer recordings weigh more
+	var voicedFrames int
+	var minHz, medianHz, maxHz, f1, f2 *float64
+	err = database.DB.QueryRow(ctx,
+		`SELECT COUNT(f0_hz),
+		        percentile_cont(0.05) WITHIN GROUP (ORDER BY f0_hz),
+		        percentile_cont(0.5) WITHIN GROUP (ORDER BY f0_hz),
+		        percentile_cont(0.95) WITHIN GROUP (ORDER BY f0_hz),
+		        percentile_cont(0.5) WITHIN GROUP (ORDER BY f1_hz),
+		        percentile_cont(0.5) WITHIN GROUP (ORDER BY f2_hz)
+		 FROM pitch_frames
+		 WHERE recording_id = ANY($1::uuid[]) AND voiced`,
+		recordingIDs).Scan(&voicedFrames, &minHz, &medianHz, &maxHz, &f1, &f2)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to measure baseline",
+		})
+		return
+	}
+	if float64(voicedFrames)*audio.PitchHopSeconds < MinBaselineVoicedSeconds {
+		c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Baseline recordings need at least %.0f seconds of voiced sound", MinBaselineVoicedSeconds),
+			Code:    "baseline_too_short",
+		})
+		return
+	}
+
+	_, err = database.DB.Exec(ctx,
+		`INSERT INTO voice_profiles (user_id, baseline_min_hz, baseline_median_hz, baseline_max_hz,
+		                             baseline_f1_hz, baseline_f2_hz, baseline_recording_ids, baseline_measured_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7::uuid[], NOW())
+		 ON CONFLICT (user_id) DO UPDATE
+		 SET baseline_min_hz = EXCLUDED.baseline_min_hz,
+		     baseline_median_hz = EXCLUDED.baseline_median_hz,
+		     baseline_max_hz = EXCLUDED.baseline_max_hz,
+		     baseline_f1_hz = EXCLUDED.baseline_f1_hz,
+		     baseline_f2_hz = EXCLUDED.baseline_f2_hz,
+		     baseline_recording_ids = EXCLUDED.baseline_recording_ids,
+		     baseline_measured_at = EXCLUDED.baseline_measured_at,
+		     updated_at = NOW()`,
+		userID, minHz, medianHz, maxHz, f1, f2, recordingIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to store baseline",
+		})
+		return
+	}
+
+	respondVoiceProfile(c, userID.(string))
+}
+
+// respondVoiceProfile writes the voice profile of userID with the pitch range
+// the tracker searches for it
+func respondVoiceProfile(c *gin.Context, userID string) {
+	profile, err := loadVoiceProfile(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch voice profile",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"profile":     profile,
+			"pitch_range": voicePitchRange(profile),
+		},
+	})
+}
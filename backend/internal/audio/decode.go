@@ -0,0 +1,346 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/jfreymuth/oggvorbis"
+)
+
+// Decoder turns an encoded audio file into mono samples in [-1, 1]
+type Decoder interface {
+	Name() string
+	// Decode returns the mono samples of inputPath and their sample rate
+	Decode(ctx context.Context, inputPath string) ([]float64, int, error)
+	// Probe reads the stream parameters of inputPath
+	Probe(ctx context.Context, inputPath string) (*StreamInfo, error)
+}
+
+// nativeDecoders maps sniffed format names to the pure-Go decoder for them
+var nativeDecoders = map[string]Decoder{
+	"wav": wavDecoder{},
+	"mp3": mp3Decoder{},
+	"ogg": vorbisDecoder{},
+}
+
+var (
+	detectOnce sync.Once
+	ffmpeg     Decoder // nil when ffmpeg and ffprobe are not installed
+)
+
+// DetectDecoders checks once whether ffmpeg is installed and returns the
+// names of the available decoding backends. The native decoders are always
+// available; ffmpeg is used for whatever they cannot handle.
+func DetectDecoders() []string {
+	detectOnce.Do(func() {
+		_, errFFmpeg := exec.LookPath("ffmpeg")
+		_, errFFprobe := exec.LookPath("ffprobe")
+		if errFFmpeg == nil && errFFprobe == nil {
+			ffmpeg = ffmpegDecoder{}
+		} else {
+			log.Println("ffmpeg not found; only WAV, MP3 and Ogg/Vorbis uploads can be processed")
+		}
+	})
+
+	backends := []string{"native (wav, mp3, ogg/vorbis)"}
+	if ffmpeg != nil {
+		backends = append(backends, ffmpeg.Name())
+	}
+	return backends
+}
+
+// decodersFor returns the decoders to try for a file, native first
+func decodersFor(inputPath string) []Decoder {
+	DetectDecoders()
+
+	var decoders []Decoder
+	if format, err := sniffFile(inputPath); err == nil && format != nil {
+		if native, ok := nativeDecoders[format.Name]; ok {
+			decoders = append(decoders, native)
+		}
+	}
+	if ffmpeg != nil {
+		decoders = append(decoders, ffmpeg)
+	}
+	return decoders
+}
+
+// DecodeAudio decodes any supported file into mono samples at SampleRate.
+// The native decoder for the format is tried first; when it fails or there
+// is none, ffmpeg is used if it is installed.
+func DecodeAudio(ctx context.Context, inputPath string) ([]float64, error) {
+	decoders := decodersFor(inputPath)
+	if len(decoders) == 0 {
+		return nil, errors.New("no decoder available for this format (ffmpeg is not installed)")
+	}
+
+	var errs []error
+	for _, d := range decoders {
+		samples, sampleRate, err := d.Decode(ctx, inputPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d.Name(), err))
+			continue
+		}
+		if sampleRate <= 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid sample rate: %d", d.Name(), sampleRate))
+			continue
+		}
+		return Resample(samples, sampleRate, SampleRate), nil
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// probeWithDecoders reads stream parameters with the same preference as DecodeAudio
+func probeWithDecoders(ctx context.Context, inputPath string) (*StreamInfo, error) {
+	decoders := decodersFor(inputPath)
+	if len(decoders) == 0 {
+		return nil, errors.New("no decoder available for this format (ffmpeg is not installed)")
+	}
+
+	var errs []error
+	for _, d := range decoders {
+		info, err := d.Probe(ctx, inputPath)
+		if err == nil {
+			return info, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", d.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+func sniffFile(inputPath string) (*Format, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header := make([]byte, SniffLength)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+
+	format, _ := SniffFormat(header[:n])
+	return format, nil
+}
+
+// Resample converts mono samples from one rate to another by linear
+// interpolation. Voice energy sits far below the Nyquist frequency of every
+// accepted rate, so the slight aliasing this allows does not affect analysis.
+func Resample(samples []float64, fromRate, toRate int) []float64 {
+	if fromRate == toRate || len(samples) == 0 {
+		return samples
+	}
+
+	ratio := float64(fromRate) / float64(toRate)
+	out := make([]float64, int(math.Floor(float64(len(samples)-1)/ratio))+1)
+	for i := range out {
+		pos := float64(i) * ratio
+		index := int(pos)
+		if index >= len(samples)-1 {
+			out[i] = samples[len(samples)-1]
+			continue
+		}
+		frac := pos - float64(index)
+		out[i] = samples[index]*(1-frac) + samples[index+1]*frac
+	}
+
+	return out
+}
+
+// wavDecoder reads PCM and float WAV files
+type wavDecoder struct{}
+
+func (wavDecoder) Name() string { return "native-wav" }
+
+func (wavDecoder) Decode(ctx context.Context, inputPath string) ([]float64, int, error) {
+	return readWAV(inputPath)
+}
+
+// Probe parses the fmt chunk and names the codec the way ffprobe does
+func (wavDecoder) Probe(ctx context.Context, inputPath string) (*StreamInfo, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var riff [12]byte
+	if _, err := io.ReadFull(file, riff[:]); err != nil {
+		return nil, fmt.Errorf("failed to read RIFF header: %w", err)
+	}
+
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(file, chunk[:]); err != nil {
+			return nil, errors.New("no fmt chunk found")
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+
+		if string(chunk[:4]) != "fmt " {
+			// Chunks are padded to an even size
+			if _, err := file.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if size < 16 {
+			return nil, errors.New("fmt chunk too short")
+		}
+		fmtChunk := make([]byte, size)
+		if _, err := io.ReadFull(file, fmtChunk); err != nil {
+			return nil, fmt.Errorf("failed to read fmt chunk: %w", err)
+		}
+
+		tag := binary.LittleEndian.Uint16(fmtChunk[0:])
+		if tag == 0xfffe && size >= 26 { // WAVE_FORMAT_EXTENSIBLE: the sub-format holds the real tag
+			tag = binary.LittleEndian.Uint16(fmtChunk[24:])
+		}
+
+		return &StreamInfo{
+			Codec:      wavCodecName(tag, int(binary.LittleEndian.Uint16(fmtChunk[14:]))),
+			SampleRate: int(binary.LittleEndian.Uint32(fmtChunk[4:])),
+			Channels:   int(binary.LittleEndian.Uint16(fmtChunk[2:])),
+		}, nil
+	}
+}
+
+func wavCodecName(tag uint16, bits int) string {
+	switch {
+	case tag == 1 && bits == 8:
+		return "pcm_u8"
+	case tag == 1:
+		return fmt.Sprintf("pcm_s%dle", bits)
+	case tag == 3:
+		return fmt.Sprintf("pcm_f%dle", bits)
+	default:
+		return fmt.Sprintf("wav_format_0x%04x", tag)
+	}
+}
+
+// mp3Decoder decodes MPEG-1/2 Layer III
+type mp3Decoder struct{}
+
+func (mp3Decoder) Name() string { return "native-mp3" }
+
+func (mp3Decoder) Decode(ctx context.Context, inputPath string) ([]float64, int, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	decoder, err := mp3.NewDecoder(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to parse MP3: %w", err)
+	}
+
+	// go-mp3 always produces 16-bit little-endian stereo
+	pcm, err := io.ReadAll(decoder)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode MP3: %w", err)
+	}
+
+	interleaved := make([]float64, len(pcm)/2)
+	for i := range interleaved {
+		interleaved[i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:]))) / 32768
+	}
+
+	return downmix(interleaved[:len(interleaved)/2*2], 2), decoder.SampleRate(), nil
+}
+
+// Probe reports the decoded channel count; go-mp3 upmixes mono streams to stereo
+func (mp3Decoder) Probe(ctx context.Context, inputPath string) (*StreamInfo, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder, err := mp3.NewDecoder(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse MP3: %w", err)
+	}
+
+	return &StreamInfo{Codec: "mp3", SampleRate: decoder.SampleRate(), Channels: 2}, nil
+}
+
+// vorbisDecoder decodes Vorbis in Ogg; Ogg/Opus is left to ffmpeg
+type vorbisDecoder struct{}
+
+func (vorbisDecoder) Name() string { return "native-vorbis" }
+
+func (vorbisDecoder) Decode(ctx context.Context, inputPath string) ([]float64, int, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	data, format, err := oggvorbis.ReadAll(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode Ogg/Vorbis: %w", err)
+	}
+	if format.Channels < 1 {
+		return nil, 0, fmt.Errorf("invalid channel count: %d", format.Channels)
+	}
+
+	interleaved := make([]float64, len(data))
+	for i, v := range data {
+		interleaved[i] = float64(v)
+	}
+
+	return downmix(interleaved, format.Channels), format.SampleRate, nil
+}
+
+func (vorbisDecoder) Probe(ctx context.Context, inputPath string) (*StreamInfo, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	format, err := oggvorbis.GetFormat(file)
+	if err != nil {
+		return nil, fmt.Errorf("not an Ogg/Vorbis stream: %w", err)
+	}
+
+	return &StreamInfo{Codec: "vorbis", SampleRate: format.SampleRate, Channels: format.Channels}, nil
+}
+
+// ffmpegDecoder handles every format by transcoding to WAV with ffmpeg
+type ffmpegDecoder struct{}
+
+func (ffmpegDecoder) Name() string { return "ffmpeg" }
+
+func (ffmpegDecoder) Decode(ctx context.Context, inputPath string) ([]float64, int, error) {
+	dir, err := os.MkdirTemp("", "transcode-")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	wavPath := filepath.Join(dir, "audio.wav")
+	if err := TranscodeToWAV(ctx, inputPath, wavPath); err != nil {
+		return nil, 0, err
+	}
+
+	return readWAV(wavPath)
+}
+
+func (ffmpegDecoder) Probe(ctx context.Context, inputPath string) (*StreamInfo, error) {
+	return ProbeStream(ctx, inputPath)
+}
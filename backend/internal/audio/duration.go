@@ -0,0 +1,54 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const (
+	DefaultMinDurationSeconds = 0.5    // Shorter clips carry too little voice to analyze
+	DefaultMaxDurationSeconds = 1800.0 // 30 minutes
+)
+
+// DurationLimits bounds the accepted recording length in seconds
+type DurationLimits struct {
+	Min float64
+	Max float64
+}
+
+// DurationLimitsFromEnv reads MIN_RECORDING_SECONDS and MAX_RECORDING_SECONDS,
+// falling back to the defaults when unset or invalid
+func DurationLimitsFromEnv() DurationLimits {
+	limits := DurationLimits{Min: DefaultMinDurationSeconds, Max: DefaultMaxDurationSeconds}
+
+	if v, err := strconv.ParseFloat(os.Getenv("MIN_RECORDING_SECONDS"), 64); err == nil && v >= 0 {
+		limits.Min = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("MAX_RECORDING_SECONDS"), 64); err == nil && v > 0 {
+		limits.Max = v
+	}
+
+	return limits
+}
+
+// DurationError reports a recording whose length is outside the limits
+type DurationError struct {
+	Duration float64
+	Limits   DurationLimits
+}
+
+func (e *DurationError) Error() string {
+	if e.Duration < e.Limits.Min {
+		return fmt.Sprintf("recording is %.1fs long, shorter than the minimum of %.1fs", e.Duration, e.Limits.Min)
+	}
+	return fmt.Sprintf("recording is %.1fs long, longer than the maximum of %.1fs", e.Duration, e.Limits.Max)
+}
+
+// Check returns a *DurationError when seconds is outside the limits
+func (l DurationLimits) Check(seconds float64) error {
+	if seconds < l.Min || seconds > l.Max {
+		return &DurationError{Duration: seconds, Limits: l}
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultFFmpegTimeout   = 5 * time.Minute
+	DefaultFFmpegMaxOutput = 512 * 1024 * 1024 // ~50 minutes of 44.1 kHz mono 16-bit WAV
+	FFmpegStderrLimit      = 8 * 1024          // Tail of stderr kept for diagnostics
+	FFprobeStdoutLimit     = 1024 * 1024
+)
+
+// FFmpegConfig controls how ffmpeg and ffprobe are run
+type FFmpegConfig struct {
+	Timeout        time.Duration // Deadline for a single invocation
+	MaxOutputBytes int64         // Largest file ffmpeg may write
+	MaxConcurrent  int           // Invocations allowed to run at once
+}
+
+// FFmpegConfigFromEnv reads FFMPEG_TIMEOUT_SECONDS, FFMPEG_MAX_OUTPUT_MB and
+// FFMPEG_MAX_CONCURRENCY, falling back to the defaults when unset or invalid
+func FFmpegConfigFromEnv() FFmpegConfig {
+	cfg := FFmpegConfig{
+		Timeout:        DefaultFFmpegTimeout,
+		MaxOutputBytes: DefaultFFmpegMaxOutput,
+		MaxConcurrent:  runtime.NumCPU(),
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("FFMPEG_TIMEOUT_SECONDS")); err == nil && v > 0 {
+		cfg.Timeout = time.Duration(v) * time.Second
+	}
+	if v, err := strconv.ParseInt(os.Getenv("FFMPEG_MAX_OUTPUT_MB"), 10, 64); err == nil && v > 0 {
+		cfg.MaxOutputBytes = v * 1024 * 1024
+	}
+	if v, err := strconv.Atoi(os.Getenv("FFMPEG_MAX_CONCURRENCY")); err == nil && v > 0 {
+		cfg.MaxConcurrent = v
+	}
+
+	return cfg
+}
+
+// CommandError is a failed ffmpeg or ffprobe invocation with its diagnostics
+type CommandError struct {
+	Command  string
+	Err      error
+	Stderr   string // Tail of the captured stderr
+	TimedOut bool
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("%s failed: %v", e.Command, e.Err)
+	if e.TimedOut {
+		msg = fmt.Sprintf("%s timed out: %v", e.Command, e.Err)
+	}
+	if line := lastLine(e.Stderr); line != "" {
+		msg += ": " + line
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error { return e.Err }
+
+// Reason summarizes the failure without stderr or the underlying error, which
+// can name files on the server, so that it can be shown to users
+func (e *CommandError) Reason() string {
+	switch {
+	case e.TimedOut:
+		return e.Command + " timed out"
+	case errors.Is(e.Err, ErrOutputTooLarge):
+		return e.Command + " failed: " + ErrOutputTooLarge.Error()
+	}
+	return e.Command + " failed"
+}
+
+// ErrOutputTooLarge reports ffmpeg output that reached MaxOutputBytes
+var ErrOutputTooLarge = errors.New("output exceeds the size limit")
+
+// FFmpegRunner runs ffmpeg and ffprobe with a deadline, an output size cap,
+// captured stderr and a bound on concurrent invocations
+type FFmpegRunner struct {
+	cfg FFmpegConfig
+	sem chan struct{}
+}
+
+func NewFFmpegRunner(cfg FFmpegConfig) *FFmpegRunner {
+	if cfg.MaxConcurrent < 1 {
+		cfg.MaxConcurrent = 1
+	}
+	return &FFmpegRunner{cfg: cfg, sem: make(chan struct{}, cfg.MaxConcurrent)}
+}
+
+// DefaultRunner is used by TranscodeToWAV and the probe helpers
+var DefaultRunner = NewFFmpegRunner(FFmpegConfigFromEnv())
+
+// run executes name with args, writing stdout to stdout when not nil
+func (r *FFmpegRunner) run(ctx context.Context, stdout *limitedBuffer, name string, args ...string) error {
+	select {
+	case r.sem <- struct{}{}:
+		defer func() { <-r.sem }()
+	case <-ctx.Done():
+		return &CommandError{Command: name, Err: ctx.Err()}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	stderr := &tailBuffer{limit: FFmpegStderrLimit}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = stderr
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	// Do not wait forever on pipes held open by a killed process's children
+	cmd.WaitDelay = 5 * time.Second
+
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	cmdErr := &CommandError{Command: name, Err: err, Stderr: stderr.String()}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		cmdErr.TimedOut = true
+		cmdErr.Err = fmt.Errorf("no result after %s", r.cfg.Timeout)
+	}
+	return cmdErr
+}
+
+// Transcode converts inputPath to a mono WAV at SampleRate in outputPath
+func (r *FFmpegRunner) Transcode(ctx context.Context, inputPath, outputPath string) error {
+	// -nostdin keeps ffmpeg from waiting on a terminal, -fs stops it at the size cap
+	err := r.run(ctx, nil, "ffmpeg",
+		"-nostdin",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", inputPath,
+		"-vn",
+		"-ar", strconv.Itoa(SampleRate),
+		"-ac", "1", // Mono
+		"-fs", strconv.FormatInt(r.cfg.MaxOutputBytes, 10),
+		"-y", // Overwrite
+		outputPath,
+	)
+	if err != nil {
+		return err
+	}
+
+	// ffmpeg exits cleanly when -fs cuts the output short
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return &CommandError{Command: "ffmpeg", Err: fmt.Errorf("no output written: %w", err)}
+	}
+	if info.Size() >= r.cfg.MaxOutputBytes {
+		os.Remove(outputPath)
+		return &CommandError{Command: "ffmpeg", Err: ErrOutputTooLarge}
+	}
+
+	return nil
+}
+
+// MediaInfo is the container and first audio stream metadata reported by ffprobe
+type MediaInfo struct {
+	FormatName string
+	Duration   float64 // Seconds, 0 when unknown
+	BitRate    int64   // Bits per second, 0 when unknown
+	Stream     *StreamInfo
+}
+
+// Probe extracts container and audio stream metadata with ffprobe
+func (r *FFmpegRunner) Probe(ctx context.Context, inputPath string) (*MediaInfo, error) {
+	stdout := &limitedBuffer{limit: FFprobeStdoutLimit}
+	err := r.run(ctx, stdout, "ffprobe",
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "format=format_name,duration,bit_rate:stream=codec_name,sample_rate,channels",
+		"-of", "json",
+		inputPath,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var probe struct {
+		Format struct {
+			FormatName string `json:"format_name"`
+			Duration   string `json:"duration"`
+			BitRate    string `json:"bit_rate"`
+		} `json:"format"`
+		Streams []struct {
+			CodecName  string `json:"codec_name"`
+			SampleRate string `json:"sample_rate"`
+			Channels   int    `json:"channels"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	info := &MediaInfo{FormatName: probe.Format.FormatName}
+	info.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	info.BitRate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+
+	if len(probe.Streams) > 0 {
+		stream := probe.Streams[0]
+		sampleRate, _ := strconv.Atoi(stream.SampleRate)
+		info.Stream = &StreamInfo{
+			Codec:      stream.CodecName,
+			SampleRate: sampleRate,
+			Channels:   stream.Channels,
+		}
+	}
+
+	return info, nil
+}
+
+// ProbeStream reads the codec, sample rate and channel count of the first
+// audio stream with ffprobe
+func ProbeStream(ctx context.Context, inputPath string) (*StreamInfo, error) {
+	info, err := DefaultRunner.Probe(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Stream == nil {
+		return nil, errors.New("no audio stream found")
+	}
+	if info.Stream.SampleRate <= 0 {
+		return nil, errors.New("ffprobe returned no sample rate")
+	}
+	return info.Stream, nil
+}
+
+// ProbeDuration reads the container duration of any audio file with ffprobe
+func ProbeDuration(ctx context.Context, inputPath string) (float64, error) {
+	info, err := DefaultRunner.Probe(ctx, inputPath)
+	if err != nil {
+		return 0, err
+	}
+	if info.Duration <= 0 {
+		return 0, errors.New("ffprobe returned no duration")
+	}
+	return info.Duration, nil
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	limit int
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.limit; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return strings.TrimSpace(string(b.buf))
+}
+
+// limitedBuffer fails writes once limit bytes have been collected, which
+// makes the command fail instead of growing memory without bound
+type limitedBuffer struct {
+	limit int
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, ErrOutputTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// lastLine returns the last non-empty line of s
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,223 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// installFakeTool puts a shell script named name first on PATH
+func installFakeTool(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// writeOutputScript writes $FAKE_OUTPUT_BYTES bytes to the last argument, where ffmpeg writes its output
+const writeOutputScript = `for last; do :; done
+head -c "$FAKE_OUTPUT_BYTES" /dev/zero > "$last"
+`
+
+func testRunner(cfg FFmpegConfig) *FFmpegRunner {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxOutputBytes == 0 {
+		cfg.MaxOutputBytes = 1024 * 1024
+	}
+	return NewFFmpegRunner(cfg)
+}
+
+func TestTranscodeSucceeds(t *testing.T) {
+	installFakeTool(t, "ffmpeg", writeOutputScript)
+	t.Setenv("FAKE_OUTPUT_BYTES", "100")
+
+	output := filepath.Join(t.TempDir(), "out.wav")
+	if err := testRunner(FFmpegConfig{}).Transcode(context.Background(), "in.webm", output); err != nil {
+		t.Fatalf("Transcode: %v", err)
+	}
+
+	info, err := os.Stat(output)
+	if err != nil || info.Size() != 100 {
+		t.Fatalf("expected 100 byte output, got %v, %v", info, err)
+	}
+}
+
+func TestTranscodeCapturesStderr(t *testing.T) {
+	installFakeTool(t, "ffmpeg", `echo "in.webm: Invalid data found when processing input" >&2
+exit 1
+`)
+
+	err := testRunner(FFmpegConfig{}).Transcode(context.Background(), "in.webm", filepath.Join(t.TempDir(), "out.wav"))
+
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected *CommandError, got %v", err)
+	}
+	if !strings.Contains(cmdErr.Stderr, "Invalid data found") {
+		t.Errorf("stderr not captured: %q", cmdErr.Stderr)
+	}
+	if !strings.Contains(err.Error(), "Invalid data found") {
+		t.Errorf("error does not mention stderr: %v", err)
+	}
+	if cmdErr.TimedOut {
+		t.Error("failure reported as timeout")
+	}
+	if reason := cmdErr.Reason(); reason != "ffmpeg failed" {
+		t.Errorf("reason = %q, want it without stderr", reason)
+	}
+}
+
+func TestTranscodeKeepsStderrTail(t *testing.T) {
+	installFakeTool(t, "ffmpeg", `head -c 20000 /dev/zero | tr '\0' 'x' >&2
+echo >&2
+echo "final error line" >&2
+exit 1
+`)
+
+	err := testRunner(FFmpegConfig{}).Transcode(context.Background(), "in.webm", filepath.Join(t.TempDir(), "out.wav"))
+
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected *CommandError, got %v", err)
+	}
+	if len(cmdErr.Stderr) > FFmpegStderrLimit {
+		t.Errorf("stderr kept %d bytes, limit is %d", len(cmdErr.Stderr), FFmpegStderrLimit)
+	}
+	if !strings.HasSuffix(cmdErr.Stderr, "final error line") {
+		t.Errorf("stderr tail lost the last line: %q", cmdErr.Stderr[len(cmdErr.Stderr)-40:])
+	}
+}
+
+func TestTranscodeTimesOut(t *testing.T) {
+	installFakeTool(t, "ffmpeg", "exec sleep 10\n")
+
+	start := time.Now()
+	err := testRunner(FFmpegConfig{Timeout: 200 * time.Millisecond}).
+		Transcode(context.Background(), "in.webm", filepath.Join(t.TempDir(), "out.wav"))
+
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || !cmdErr.TimedOut {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if reason := cmdErr.Reason(); reason != "ffmpeg timed out" {
+		t.Errorf("reason = %q, want ffmpeg timed out", reason)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("timeout took %s to take effect", elapsed)
+	}
+}
+
+func TestTranscodeRejectsOversizedOutput(t *testing.T) {
+	installFakeTool(t, "ffmpeg", writeOutputScript)
+	t.Setenv("FAKE_OUTPUT_BYTES", "2048")
+
+	output := filepath.Join(t.TempDir(), "out.wav")
+	err := testRunner(FFmpegConfig{MaxOutputBytes: 1024}).Transcode(context.Background(), "in.webm", output)
+	if !errors.Is(err, ErrOutputTooLarge) {
+		t.Fatalf("expected ErrOutputTooLarge, got %v", err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Error("oversized output was left behind")
+	}
+}
+
+func TestRunnerLimitsConcurrency(t *testing.T) {
+	lock := filepath.Join(t.TempDir(), "running")
+	installFakeTool(t, "ffmpeg", `if [ -e "$FAKE_LOCK" ]; then echo "overlapping run" >&2; exit 1; fi
+touch "$FAKE_LOCK"
+sleep 0.1
+rm "$FAKE_LOCK"
+`+writeOutputScript)
+	t.Setenv("FAKE_LOCK", lock)
+	t.Setenv("FAKE_OUTPUT_BYTES", "10")
+
+	runner := testRunner(FFmpegConfig{MaxConcurrent: 1})
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = runner.Transcode(context.Background(), "in.webm", filepath.Join(dir, strings.Repeat("o", i+1)+".wav"))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("run %d: %v", i, err)
+		}
+	}
+}
+
+func TestRunnerHonoursCancelledContext(t *testing.T) {
+	installFakeTool(t, "ffmpeg", "exec sleep 10\n")
+
+	runner := testRunner(FFmpegConfig{MaxConcurrent: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Occupy the only slot, then ask for another with a cancelled context
+	done := make(chan struct{})
+	go func() {
+		runner.Transcode(ctx, "in.webm", filepath.Join(t.TempDir(), "a.wav"))
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer waitCancel()
+	err := runner.Transcode(waitCtx, "in.webm", filepath.Join(t.TempDir(), "b.wav"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the queued run to give up, got %v", err)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestProbeParsesMetadata(t *testing.T) {
+	installFakeTool(t, "ffprobe", `cat <<'JSON'
+{
+  "streams": [{"codec_name": "opus", "sample_rate": "48000", "channels": 1}],
+  "format": {"format_name": "matroska,webm", "duration": "12.345000", "bit_rate": "64000"}
+}
+JSON
+`)
+
+	info, err := testRunner(FFmpegConfig{}).Probe(context.Background(), "in.webm")
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+
+	if info.FormatName != "matroska,webm" || info.Duration != 12.345 || info.BitRate != 64000 {
+		t.Errorf("unexpected format metadata: %+v", info)
+	}
+	if info.Stream == nil || *info.Stream != (StreamInfo{Codec: "opus", SampleRate: 48000, Channels: 1}) {
+		t.Errorf("unexpected stream metadata: %+v", info.Stream)
+	}
+}
+
+func TestProbeCapsOutput(t *testing.T) {
+	installFakeTool(t, "ffprobe", "head -c 2000000 /dev/zero\n")
+
+	_, err := testRunner(FFmpegConfig{}).Probe(context.Background(), "in.webm")
+	if err == nil {
+		t.Fatal("expected oversized ffprobe output to fail")
+	}
+}
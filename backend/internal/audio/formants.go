@@ -0,0 +1,272 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+	"sort"
+)
+
+const (
+	FormantTargetRate     = 11025.0 // Formants of interest lie below ~5 kHz
+	FormantFrameSeconds   = 0.025   // 25 ms LPC window
+	FormantPreEmphasis    = 0.97
+	FormantMinHz          = 90.0  // Roots below this are spectral tilt, not formants
+	FormantMaxBandwidthHz = 400.0 // Wider resonances are not formants
+)
+
+// FormantFrame holds the first three formants at one pitch frame; 0 means not found
+type FormantFrame struct {
+	Time float64
+	F1   float64
+	F2   float64
+	F3   float64
+}
+
+// FormantTrack holds per-frame formants and their medians over voiced frames
+type FormantTrack struct {
+	Frames   []FormantFrame // Aligned index-for-index with the pitch frames
+	MedianF1 float64
+	MedianF2 float64
+	MedianF3 float64
+}
+
+// TrackFormants estimates F1-F3 with LPC at every voiced frame of pitch
+func TrackFormants(samples []float64, sampleRate int, pitch *PitchTrack) *FormantTrack {
+	track := &FormantTrack{Frames: make([]FormantFrame, len(pitch.Frames))}
+
+	// Decimate so the LPC model spends its poles on the formant range
+	factor := int(float64(sampleRate) / FormantTargetRate)
+	if factor < 1 {
+		factor = 1
+	}
+	decimated := decimate(samples, factor)
+	rate := float64(sampleRate) / float64(factor)
+
+	frameSize := int(FormantFrameSeconds * rate)
+	order := 2 + int(rate/1000)
+	window := hammingWindow(frameSize)
+	frame := make([]float64, frameSize)
+
+	var f1s, f2s, f3s []float64
+	for i, pf := range pitch.Frames {
+		track.Frames[i].Time = pf.Time
+		if !pf.Voiced {
+			continue
+		}
+
+		start := int(pf.Time*rate) - frameSize/2
+		if start < 1 || start+frameSize > len(decimated) {
+			continue
+		}
+
+		// Pre-emphasis flattens the glottal tilt before windowing
+		for j := 0; j < frameSize; j++ {
+			n := start + j
+			frame[j] = (decimated[n] - FormantPreEmphasis*decimated[n-1]) * window[j]
+		}
+
+		formants := lpcFormants(frame, order, rate)
+		if len(formants) > 0 {
+			track.Frames[i].F1 = formants[0]
+			f1s = append(f1s, formants[0])
+		}
+		if len(formants) > 1 {
+			track.Frames[i].F2 = formants[1]
+			f2s = append(f2s, formants[1])
+		}
+		if len(formants) > 2 {
+			track.Frames[i].F3 = formants[2]
+			f3s = append(f3s, formants[2])
+		}
+	}
+
+	track.MedianF1 = median(f1s)
+	track.MedianF2 = median(f2s)
+	track.MedianF3 = median(f3s)
+	return track
+}
+
+// lpcFormants fits an all-pole model to frame and returns the resonance
+// frequencies of its complex roots in ascending order
+func lpcFormants(frame []float64, order int, rate float64) []float64 {
+	coeffs, ok := lpc(frame, order)
+	if !ok {
+		return nil
+	}
+
+	var formants []float64
+	for _, root := range polynomialRoots(coeffs) {
+		if imag(root) <= 0 {
+			continue
+		}
+
+		freq := cmplx.Phase(root) * rate / (2 * math.Pi)
+		bandwidth := -math.Log(cmplx.Abs(root)) * rate / math.Pi
+		if freq > FormantMinHz && freq < rate/2-FormantMinHz && bandwidth < FormantMaxBandwidthHz {
+			formants = append(formants, freq)
+		}
+	}
+
+	sort.Float64s(formants)
+	return formants
+}
+
+// lpc returns the prediction polynomial [1, a1, ..., ap] of frame using the
+// autocorrelation method and Levinson-Durbin recursion
+func lpc(frame []float64, order int) ([]float64, bool) {
+	r := make([]float64, order+1)
+	for lag := 0; lag <= order; lag++ {
+		for i := lag; i < len(frame); i++ {
+			r[lag] += frame[i] * frame[i-lag]
+		}
+	}
+	if r[0] == 0 {
+		return nil, false
+	}
+
+	a := make([]float64, order+1)
+	a[0] = 1
+	prediction := r[0]
+	tmp := make([]float64, order+1)
+
+	for i := 1; i <= order; i++ {
+		acc := r[i]
+		for j := 1; j < i; j++ {
+			acc += a[j] * r[i-j]
+		}
+		k := -acc / prediction
+
+		copy(tmp, a)
+		for j := 1; j < i; j++ {
+			a[j] = tmp[j] + k*tmp[i-j]
+		}
+		a[i] = k
+
+		prediction *= 1 - k*k
+		if prediction <= 0 {
+			return nil, false
+		}
+	}
+
+	return a, true
+}
+
+// polynomialRoots finds the roots of the monic polynomial
+// z^n + c[1] z^(n-1) + ... + c[n] with the Durand-Kerner iteration
+func polynomialRoots(c []float64) []complex128 {
+	n := len(c) - 1
+	if n < 1 {
+		return nil
+	}
+
+	eval := func(z complex128) complex128 {
+		result := complex(1, 0)
+		for i := 1; i <= n; i++ {
+			result = result*z + complex(c[i], 0)
+		}
+		return result
+	}
+
+	// Standard starting points spread on a spiral inside the unit circle
+	roots := make([]complex128, n)
+	seed := complex(0.4, 0.9)
+	roots[0] = 1
+	for i := 1; i < n; i++ {
+		roots[i] = roots[i-1] * seed
+	}
+
+	for iter := 0; iter < 500; iter++ {
+		maxDelta := 0.0
+		for i := range roots {
+			denominator := complex(1, 0)
+			for j := range roots {
+				if i != j {
+					denominator *= roots[i] - roots[j]
+				}
+			}
+			if denominator == 0 {
+				denominator = complex(1e-12, 0)
+			}
+
+			delta := eval(roots[i]) / denominator
+			roots[i] -= delta
+			maxDelta = math.Max(maxDelta, cmplx.Abs(delta))
+		}
+		if maxDelta < 1e-10 {
+			break
+		}
+	}
+
+	return roots
+}
+
+// decimate low-pass filters samples and keeps every factor-th sample
+func decimate(samples []float64, factor int) []float64 {
+	if factor <= 1 {
+		return samples
+	}
+
+	kernel := lowPassKernel(0.5/float64(factor)*0.9, 8*factor+1)
+	half := len(kernel) / 2
+
+	out := make([]float64, len(samples)/factor)
+	for i := range out {
+		centre := i * factor
+		sum := 0.0
+		for k, w := range kernel {
+			n := centre + k - half
+			if n >= 0 && n < len(samples) {
+				sum += samples[n] * w
+			}
+		}
+		out[i] = sum
+	}
+
+	return out
+}
+
+// lowPassKernel builds a Hamming-windowed sinc FIR filter. cutoff is a
+// fraction of the sample rate and taps should be odd.
+func lowPassKernel(cutoff float64, taps int) []float64 {
+	kernel := make([]float64, taps)
+	window := hammingWindow(taps)
+	half := taps / 2
+
+	sum := 0.0
+	for i := range kernel {
+		x := float64(i - half)
+		if x == 0 {
+			kernel[i] = 2 * cutoff
+		} else {
+			kernel[i] = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		kernel[i] *= window[i]
+		sum += kernel[i]
+	}
+
+	// Normalize for unity gain at DC
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	return kernel
+}
+
+// hammingWindow returns Hamming window coefficients of length n
+func hammingWindow(n int) []float64 {
+	ones := make([]float64, n)
+	for i := range ones {
+		ones[i] = 1
+	}
+	return applyHammingWindow(ones)
+}
+
+// median returns the median of values without modifying them, or 0 when empty
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return percentile(sorted, 50)
+}
@@ -0,0 +1,294 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+	"sort"
+
+	"github.com/mjibson/go-dsp/fft"
+)
+
+const (
+	PitchHopSeconds = 0.01  // 10 ms between analysis frames
+	YINThreshold    = 0.15  // CMND threshold below which a frame counts as voiced
+	SilenceRMS      = 0.005 // Frames quieter than this (~ -46 dBFS) are never voiced
+)
+
+// PitchFrame is one analysis frame of a pitch contour
+type PitchFrame struct {
+	Time       float64 // Frame centre in seconds
+	F0         float64 // Fundamental frequency in Hz, 0 when unvoiced
+	Confidence float64 // Periodicity confidence in [0, 1]
+	Voiced     bool
+}
+
+// PitchSummary holds robust statistics over the voiced frames of a contour
+type PitchSummary struct {
+	MedianHz     float64
+	MeanHz       float64
+	MinHz        float64
+	MaxHz        float64
+	P10Hz        float64
+	P90Hz        float64
+	StdDevHz     float64
+	VoicedFrames int
+	TotalFrames  int
+}
+
+// VoicedRatio returns the fraction of frames that were voiced
+func (s PitchSummary) VoicedRatio() float64 {
+	if s.TotalFrames == 0 {
+		return 0
+	}
+	return float64(s.VoicedFrames) / float64(s.TotalFrames)
+}
+
+// PitchTrack is a frame-by-frame F0 contour with its summary
+type PitchTrack struct {
+	HopSeconds float64
+	Frames     []PitchFrame
+	Summary    PitchSummary
+}
+
+// TrackPitch runs a YIN pitch tracker over samples in overlapping frames.
+// minHz and maxHz bound the lags searched in each frame.
+func TrackPitch(samples []float64, sampleRate int, minHz, maxHz float64) *PitchTrack {
+	minTau := int(math.Floor(float64(sampleRate) / maxHz))
+	maxTau := int(math.Ceil(float64(sampleRate) / minHz))
+	if minTau < 2 {
+		minTau = 2
+	}
+
+	// Each frame must hold at least two periods of the lowest pitch
+	frameSize := nextPowerOfTwo(2 * maxTau)
+	hopSize := int(float64(sampleRate) * PitchHopSeconds)
+	if hopSize < 1 {
+		hopSize = 1
+	}
+
+	track := &PitchTrack{HopSeconds: float64(hopSize) / float64(sampleRate)}
+
+	yin := newYINAnalyzer(frameSize, minTau, maxTau)
+	for start := 0; start+frameSize <= len(samples); start += hopSize {
+		frame := samples[start : start+frameSize]
+		f0, confidence := yin.analyze(frame, float64(sampleRate))
+
+		voiced := f0 > 0 && rms(frame) >= SilenceRMS
+		if !voiced {
+			f0 = 0
+		}
+
+		track.Frames = append(track.Frames, PitchFrame{
+			Time:       (float64(start) + float64(frameSize)/2) / float64(sampleRate),
+			F0:         f0,
+			Confidence: confidence,
+			Voiced:     voiced,
+		})
+	}
+
+	track.Summary = summarizePitch(track.Frames)
+	return track
+}
+
+// yinAnalyzer holds the per-frame buffers reused across a YIN run
+type yinAnalyzer struct {
+	frameSize int
+	window    int
+	minTau    int
+	maxTau    int
+	fftSize   int
+	diff      []float64
+	cmnd      []float64
+	padA      []float64
+	padB      []float64
+	energy    []float64
+}
+
+func newYINAnalyzer(frameSize, minTau, maxTau int) *yinAnalyzer {
+	window := frameSize - maxTau
+	fftSize := nextPowerOfTwo(frameSize + window)
+
+	return &yinAnalyzer{
+		frameSize: frameSize,
+		window:    window,
+		minTau:    minTau,
+		maxTau:    maxTau,
+		fftSize:   fftSize,
+		diff:      make([]float64, maxTau+1),
+		cmnd:      make([]float64, maxTau+1),
+		padA:      make([]float64, fftSize),
+		padB:      make([]float64, fftSize),
+		energy:    make([]float64, frameSize+1),
+	}
+}
+
+// analyze returns the estimated F0 of a frame and its periodicity confidence.
+// It returns an F0 of 0 when no lag falls below the YIN threshold.
+func (y *yinAnalyzer) analyze(frame []float64, sampleRate float64) (float64, float64) {
+	y.difference(frame)
+
+	// Cumulative mean normalized difference
+	y.cmnd[0] = 1
+	running := 0.0
+	for tau := 1; tau <= y.maxTau; tau++ {
+		running += y.diff[tau]
+		if running == 0 {
+			y.cmnd[tau] = 1
+		} else {
+			y.cmnd[tau] = y.diff[tau] * float64(tau) / running
+		}
+	}
+
+	// Absolute threshold: take the first dip below threshold, then walk to its minimum
+	bestTau := -1
+	for tau := y.minTau; tau <= y.maxTau; tau++ {
+		if y.cmnd[tau] < YINThreshold {
+			for tau+1 <= y.maxTau && y.cmnd[tau+1] < y.cmnd[tau] {
+				tau++
+			}
+			bestTau = tau
+			break
+		}
+	}
+
+	if bestTau < 0 {
+		// No clear period; report the global minimum's confidence only
+		minValue := 1.0
+		for tau := y.minTau; tau <= y.maxTau; tau++ {
+			if y.cmnd[tau] < minValue {
+				minValue = y.cmnd[tau]
+			}
+		}
+		return 0, clamp01(1 - minValue)
+	}
+
+	confidence := clamp01(1 - y.cmnd[bestTau])
+	refined := parabolicInterpolation(y.cmnd, bestTau)
+	if refined <= 0 {
+		return 0, confidence
+	}
+
+	return sampleRate / refined, confidence
+}
+
+// difference computes the YIN difference function d(tau) for every lag.
+// The cross term is computed as an FFT cross-correlation, which keeps the
+// cost per frame at O(n log n) instead of O(n * maxTau).
+func (y *yinAnalyzer) difference(frame []float64) {
+	for i := range y.padA {
+		y.padA[i] = 0
+		y.padB[i] = 0
+	}
+	copy(y.padA, frame[:y.window])
+	copy(y.padB, frame)
+
+	specA := fft.FFTReal(y.padA)
+	specB := fft.FFTReal(y.padB)
+	for i := range specA {
+		specA[i] = cmplx.Conj(specA[i]) * specB[i]
+	}
+	corr := fft.IFFT(specA)
+
+	// Prefix sums of squared samples give the sliding window energy
+	y.energy[0] = 0
+	for i, s := range frame {
+		y.energy[i+1] = y.energy[i] + s*s
+	}
+
+	base := y.energy[y.window]
+	y.diff[0] = 0
+	for tau := 1; tau <= y.maxTau; tau++ {
+		shifted := y.energy[tau+y.window] - y.energy[tau]
+		d := base + shifted - 2*real(corr[tau])
+		if d < 0 {
+			d = 0
+		}
+		y.diff[tau] = d
+	}
+}
+
+// parabolicInterpolation refines a lag estimate to sub-sample precision
+func parabolicInterpolation(values []float64, index int) float64 {
+	if index <= 0 || index >= len(values)-1 {
+		return float64(index)
+	}
+
+	left, centre, right := values[index-1], values[index], values[index+1]
+	denominator := left - 2*centre + right
+	if denominator == 0 {
+		return float64(index)
+	}
+
+	return float64(index) + 0.5*(left-right)/denominator
+}
+
+// summarizePitch computes statistics over the voiced frames of a contour
+func summarizePitch(frames []PitchFrame) PitchSummary {
+	summary := PitchSummary{TotalFrames: len(frames)}
+
+	voiced := make([]float64, 0, len(frames))
+	for _, f := range frames {
+		if f.Voiced {
+			voiced = append(voiced, f.F0)
+		}
+	}
+	summary.VoicedFrames = len(voiced)
+	if len(voiced) == 0 {
+		return summary
+	}
+
+	sort.Float64s(voiced)
+
+	sum := 0.0
+	for _, v := range voiced {
+		sum += v
+	}
+	mean := sum / float64(len(voiced))
+
+	variance := 0.0
+	for _, v := range voiced {
+		variance += (v - mean) * (v - mean)
+	}
+
+	summary.MeanHz = mean
+	summary.StdDevHz = math.Sqrt(variance / float64(len(voiced)))
+	summary.MinHz = voiced[0]
+	summary.MaxHz = voiced[len(voiced)-1]
+	summary.MedianHz = percentile(voiced, 50)
+	summary.P10Hz = percentile(voiced, 10)
+	summary.P90Hz = percentile(voiced, 90)
+
+	return summary
+}
+
+// percentile returns the p-th percentile of sorted values using linear interpolation
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if upper >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+
+	fraction := rank - float64(lower)
+	return sorted[lower] + (sorted[upper]-sorted[lower])*fraction
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
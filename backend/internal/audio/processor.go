@@ -1,114 +1,57 @@
 package audio
 
 import (
+	"context"
 	"fmt"
 	"math"
-	"os"
-	"os/exec"
 	"path/filepath"
-
-	"github.com/mjibson/go-dsp/fft"
-	"github.com/mjibson/go-dsp/wav"
 )
 
 const (
-	SampleRate     = 44100 // 44.1 kHz
-	MinPitchHz     = 50.0  // Minimum detectable pitch (very low bass)
-	MaxPitchHz     = 500.0 // Maximum detectable pitch (high voice)
-	FFTSize        = 8192  // FFT window size for pitch detection
-	ProcessedDir   = "uploads/processed"
-)
-
-// TranscodeToWAV converts audio file to WAV format using ffmpeg
-func TranscodeToWAV(inputPath string) (string, error) {
-	// Create processed directory if not exists
-	if err := os.MkdirAll(ProcessedDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create processed directory: %w", err)
-	}
+	SampleRate = 44100 // 44.1 kHz
+	MinPitchHz = 50.0  // Minimum detectable pitch (very low bass)
+	MaxPitchHz = 500.0 // Maximum detectable pitch (high voice)
 
-	// Generate output filename
-	baseName := filepath.Base(inputPath)
-	ext := filepath.Ext(baseName)
-	wavName := baseName[:len(baseName)-len(ext)] + ".wav"
-	outputPath := filepath.Join(ProcessedDir, wavName)
-
-	// Run ffmpeg to transcode
-	// -i input, -ar sample rate, -ac channels (mono), -y overwrite
-	cmd := exec.Command("ffmpeg",
-		"-i", inputPath,
-		"-ar", fmt.Sprintf("%d", SampleRate),
-		"-ac", "1", // Mono
-		"-y", // Overwrite
-		outputPath,
-	)
-
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("ffmpeg transcoding failed: %w", err)
-	}
+	PitchSearchMarginSemitones = 7.0 // Headroom around a voice's known range, so excursions are still tracked
+)
 
-	return outputPath, nil
+// PitchRange is a band of fundamental frequencies
+type PitchRange struct {
+	MinHz float64 `json:"min_hz"`
+	MaxHz float64 `json:"max_hz"`
 }
 
-// DetectPitch analyzes WAV file and returns dominant pitch in Hz
-func DetectPitch(wavPath string) (float64, error) {
-	// Open WAV file
-	file, err := os.Open(wavPath)
-	if err != nil {
-		return 0, fmt.Errorf("failed to open WAV file: %w", err)
-	}
-	defer file.Close()
-
-	// Read WAV data
-	wavData, err := wav.New(file)
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse WAV file: %w", err)
-	}
+// DefaultPitchRange is searched for voices whose range is unknown
+var DefaultPitchRange = PitchRange{MinHz: MinPitchHz, MaxHz: MaxPitchHz}
 
-	// Read all audio samples
-	rawSamples, err := wavData.ReadFloats(FFTSize * 2) // Read extra to ensure we have enough
-	if err != nil {
-		return 0, fmt.Errorf("failed to read WAV samples: %w", err)
+// SearchRange returns the range the pitch tracker searches for a voice that
+// spans bands: their union widened by PitchSearchMarginSemitones either side
+// and clamped to DefaultPitchRange. Without bands it is DefaultPitchRange.
+func SearchRange(bands ...PitchRange) PitchRange {
+	if len(bands) == 0 {
+		return DefaultPitchRange
 	}
 
-	// Convert to float64 samples
-	samples := make([]float64, len(rawSamples))
-	for i, s := range rawSamples {
-		samples[i] = float64(s)
+	r := bands[0]
+	for _, b := range bands[1:] {
+		r.MinHz = math.Min(r.MinHz, b.MinHz)
+		r.MaxHz = math.Max(r.MaxHz, b.MaxHz)
 	}
 
-	if len(samples) < FFTSize {
-		// Pad with zeros if not enough samples
-		for len(samples) < FFTSize {
-			samples = append(samples, 0)
-		}
+	margin := math.Pow(2, PitchSearchMarginSemitones/12)
+	return PitchRange{
+		MinHz: math.Max(r.MinHz/margin, MinPitchHz),
+		MaxHz: math.Min(r.MaxHz*margin, MaxPitchHz),
 	}
+}
 
-	// Apply Hamming window to reduce spectral leakage
-	windowed := applyHammingWindow(samples[:FFTSize])
-
-	// Perform FFT
-	fftResult := fft.FFTReal(windowed)
-
-	// Find dominant frequency
-	maxMagnitude := 0.0
-	maxIndex := 0
-
-	// Only check frequencies in voice range
-	minBin := int(math.Floor(MinPitchHz * FFTSize / SampleRate))
-	maxBin := int(math.Ceil(MaxPitchHz * FFTSize / SampleRate))
-
-	for i := minBin; i < maxBin && i < len(fftResult)/2; i++ {
-		magnitude := math.Abs(real(fftResult[i])) + math.Abs(imag(fftResult[i]))
-		if magnitude > maxMagnitude {
-			maxMagnitude = magnitude
-			maxIndex = i
-		}
+// TranscodeToWAV converts audio file to WAV format at outputPath using ffmpeg
+func TranscodeToWAV(ctx context.Context, inputPath, outputPath string) error {
+	if err := DefaultRunner.Transcode(ctx, inputPath, outputPath); err != nil {
+		return fmt.Errorf("ffmpeg transcoding failed: %w", err)
 	}
 
-	// Calculate pitch from bin index
-	pitch := float64(maxIndex) * SampleRate / FFTSize
-
-	return pitch, nil
+	return nil
 }
 
 // applyHammingWindow applies Hamming window function to reduce spectral leakage
@@ -125,19 +68,98 @@ func applyHammingWindow(samples []float64) []float64 {
 	return windowed
 }
 
-// ProcessAudioFile transcodes audio and detects pitch
-func ProcessAudioFile(inputPath string) (wavPath string, pitchHz float64, err error) {
-	// Transcode to WAV
-	wavPath, err = TranscodeToWAV(inputPath)
-	if err != nil {
-		return "", 0, fmt.Errorf("transcoding failed: %w", err)
+// ProcessOptions controls how a recording is analyzed
+type ProcessOptions struct {
+	Durations  DurationLimits
+	PitchRange PitchRange // Band the pitch tracker searches
+}
+
+// DefaultProcessOptions returns the options used when the caller has no overrides
+func DefaultProcessOptions() ProcessOptions {
+	return ProcessOptions{
+		Durations:  DurationLimitsFromEnv(),
+		PitchRange: DefaultPitchRange,
+	}
+}
+
+// Analysis is the result of processing one uploaded recording. The paths
+// name the files written to the work directory.
+type Analysis struct {
+	WAVPath         string
+	WaveformPath    string
+	SpectrogramPath string
+	Duration        float64 // Seconds
+	VAD             *VADResult
+	Pitch           *PitchTrack
+	Formants        *FormantTrack
+	Quality         *VoiceQuality // nil when there were too few voiced periods
+}
+
+// ProcessAudioFile decodes audio, measures its duration, segments it into
+// speech and silence, tracks the pitch and formants of the speech and measures
+// its voice quality. It also renders waveform peaks and a spectrogram image.
+// The decoded audio is saved as a WAV; it and the renderings are written to workDir for the caller to store.
+// A recording outside opts.Durations fails with a *DurationError.
+func ProcessAudioFile(ctx context.Context, inputPath, workDir string, opts ProcessOptions) (*Analysis, error) {
+	analysis := &Analysis{
+		WAVPath:         filepath.Join(workDir, "audio.wav"),
+		WaveformPath:    filepath.Join(workDir, "waveform.json"),
+		SpectrogramPath: filepath.Join(workDir, "spectrogram.png"),
 	}
 
-	// Detect pitch
-	pitchHz, err = DetectPitch(wavPath)
+	// Decode to mono at SampleRate, natively where possible
+	samples, err := DecodeAudio(ctx, inputPath)
 	if err != nil {
-		return wavPath, 0, fmt.Errorf("pitch detection failed: %w", err)
+		return nil, fmt.Errorf("decoding failed: %w", err)
 	}
+	sampleRate := SampleRate
 
-	return wavPath, pitchHz, nil
+	if err := writeWAVFile(analysis.WAVPath, samples, sampleRate); err != nil {
+		return nil, fmt.Errorf("transcoding failed: %w", err)
+	}
+
+	// Duration comes from the decoded sample count; fall back to the container
+	duration := float64(len(samples)) / float64(sampleRate)
+	if duration == 0 {
+		if probed, err := ProbeDuration(ctx, inputPath); err == nil {
+			duration = probed
+		}
+	}
+	if err := opts.Durations.Check(duration); err != nil {
+		return nil, err
+	}
+
+	// Find speech, then track pitch only inside it
+	vad := DetectVoiceActivity(samples, sampleRate)
+	pitch := TrackPitch(samples, sampleRate, opts.PitchRange.MinHz, opts.PitchRange.MaxHz)
+	GatePitch(pitch, vad)
+
+	// Formants are estimated at the voiced pitch frames
+	formants := TrackFormants(samples, sampleRate, pitch)
+	quality := MeasureVoiceQuality(samples, sampleRate, pitch)
+
+	// Render the waveform peaks and spectrogram next to the WAV
+	if err := RenderVisuals(analysis.WaveformPath, analysis.SpectrogramPath, samples, sampleRate); err != nil {
+		return nil, fmt.Errorf("rendering failed: %w", err)
+	}
+
+	analysis.Duration = duration
+	analysis.VAD = vad
+	analysis.Pitch = pitch
+	analysis.Formants = formants
+	analysis.Quality = quality
+	return analysis, nil
+}
+
+// writeWAVFile saves mono samples as a 16-bit WAV
+func writeWAVFile(wavPath string, samples []float64, sampleRate int) error {
+	w, err := CreateWAV(wavPath, sampleRate)
+	if err != nil {
+		return err
+	}
+	if err := w.WriteSamples(samples); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
 }
@@ -0,0 +1,352 @@
+package audio
+
+import (
+	"math"
+)
+
+const (
+	MaxPeriodFactor    = 1.3 // Adjacent periods differing by more than this are not compared
+	MaxAmplitudeFactor = 1.6 // Adjacent amplitudes differing by more than this are not compared
+	HNRPeriods         = 3   // Periods per autocorrelation window when measuring HNR
+)
+
+// VoiceQuality holds the standard perturbation and noise measures of a voice.
+// Jitter and shimmer are percentages, HNR is in dB. A measure is nil when no
+// period, amplitude or frame qualified for it.
+type VoiceQuality struct {
+	JitterLocal  *float64 // Mean absolute difference of consecutive periods / mean period
+	JitterRAP    *float64 // Relative average perturbation over 3 periods
+	ShimmerLocal *float64 // Mean absolute difference of consecutive amplitudes / mean amplitude
+	ShimmerAPQ11 *float64 // 11-point amplitude perturbation quotient
+	HNR          *float64 // Harmonics-to-noise ratio
+	Periods      int      // Glottal periods the measures are based on
+}
+
+// glottalCycle is one pitch period found in the waveform
+type glottalCycle struct {
+	Period    float64 // Seconds
+	Amplitude float64 // Peak absolute amplitude
+}
+
+// MeasureVoiceQuality computes jitter, shimmer and HNR over the voiced frames
+// of pitch. It returns nil when there are too few periods to measure.
+func MeasureVoiceQuality(samples []float64, sampleRate int, pitch *PitchTrack) *VoiceQuality {
+	runs := voicedRuns(pitch)
+
+	var cycleRuns [][]glottalCycle
+	total := 0
+	for _, run := range runs {
+		cycles := findCycles(samples, sampleRate, pitch, run)
+		if len(cycles) >= 2 {
+			cycleRuns = append(cycleRuns, cycles)
+			total += len(cycles)
+		}
+	}
+	if total < 3 {
+		return nil
+	}
+
+	return &VoiceQuality{
+		JitterLocal:  measured(jitterLocal(cycleRuns)),
+		JitterRAP:    measured(jitterRAP(cycleRuns)),
+		ShimmerLocal: measured(shimmerLocal(cycleRuns)),
+		ShimmerAPQ11: measured(shimmerAPQ11(cycleRuns)),
+		HNR:          measured(harmonicsToNoise(samples, sampleRate, pitch)),
+		Periods:      total,
+	}
+}
+
+// voicedRuns returns [first, last] frame indexes of each run of voiced frames
+func voicedRuns(pitch *PitchTrack) [][2]int {
+	var runs [][2]int
+	start := -1
+	for i, f := range pitch.Frames {
+		if f.Voiced && start < 0 {
+			start = i
+		}
+		if !f.Voiced && start >= 0 {
+			runs = append(runs, [2]int{start, i - 1})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		runs = append(runs, [2]int{start, len(pitch.Frames) - 1})
+	}
+	return runs
+}
+
+// findCycles walks a voiced run peak to peak, using the pitch contour to
+// predict where the next glottal pulse should fall
+func findCycles(samples []float64, sampleRate int, pitch *PitchTrack, run [2]int) []glottalCycle {
+	sr := float64(sampleRate)
+	half := pitch.HopSeconds / 2
+	runStart := int((pitch.Frames[run[0]].Time - half) * sr)
+	runEnd := int((pitch.Frames[run[1]].Time + half) * sr)
+	if runStart < 0 {
+		runStart = 0
+	}
+	if runEnd > len(samples) {
+		runEnd = len(samples)
+	}
+
+	f0At := func(pos float64) float64 {
+		i := run[0] + int((pos/sr-pitch.Frames[run[0]].Time)/pitch.HopSeconds+0.5)
+		if i < run[0] {
+			i = run[0]
+		}
+		if i > run[1] {
+			i = run[1]
+		}
+		return pitch.Frames[i].F0
+	}
+
+	// Anchor on the largest peak of the first period and follow its polarity
+	period := sr / f0At(float64(runStart))
+	first := argMaxAbs(samples, runStart, runStart+int(period))
+	if first < 0 {
+		return nil
+	}
+	polarity := 1.0
+	if samples[first] < 0 {
+		polarity = -1
+	}
+
+	// Collect pulse positions and heights, then pair consecutive pulses
+	pos, amp := refinePeak(samples, first, polarity)
+	positions := []float64{pos}
+	amplitudes := []float64{amp}
+	for {
+		period = sr / f0At(pos)
+		lo := int(pos + 0.8*period)
+		hi := int(pos + 1.2*period)
+		if hi >= runEnd {
+			break
+		}
+
+		next := argMaxSigned(samples, lo, hi, polarity)
+		if next < 0 {
+			break
+		}
+		pos, amp = refinePeak(samples, next, polarity)
+		positions = append(positions, pos)
+		amplitudes = append(amplitudes, amp)
+	}
+
+	cycles := make([]glottalCycle, 0, len(positions)-1)
+	for i := 1; i < len(positions); i++ {
+		cycles = append(cycles, glottalCycle{
+			Period:    (positions[i] - positions[i-1]) / sr,
+			Amplitude: amplitudes[i-1],
+		})
+	}
+
+	return cycles
+}
+
+// meanPeriod returns the mean period of the cycles in runs
+func meanPeriod(runs [][]glottalCycle) float64 {
+	sum, n := 0.0, 0
+	for _, cycles := range runs {
+		for _, c := range cycles {
+			sum += c.Period
+			n++
+		}
+	}
+	v, _ := mean(sum, n)
+	return v
+}
+
+// meanAmplitude returns the mean amplitude of the cycles in runs
+func meanAmplitude(runs [][]glottalCycle) float64 {
+	sum, n := 0.0, 0
+	for _, cycles := range runs {
+		for _, c := range cycles {
+			sum += c.Amplitude
+			n++
+		}
+	}
+	v, _ := mean(sum, n)
+	return v
+}
+
+// jitterLocal returns local jitter in percent over consecutive periods
+func jitterLocal(runs [][]glottalCycle) (float64, bool) {
+	sum, n := 0.0, 0
+	for _, cycles := range runs {
+		for i := 0; i+1 < len(cycles); i++ {
+			if withinFactor(cycles[i].Period, cycles[i+1].Period, MaxPeriodFactor) {
+				sum += math.Abs(cycles[i].Period - cycles[i+1].Period)
+				n++
+			}
+		}
+	}
+	return percentOf(sum, n, meanPeriod(runs))
+}
+
+// jitterRAP returns the relative average perturbation in percent over
+// triples of consecutive periods
+func jitterRAP(runs [][]glottalCycle) (float64, bool) {
+	sum, n := 0.0, 0
+	for _, cycles := range runs {
+		for i := 1; i+1 < len(cycles); i++ {
+			prev, c, next := cycles[i-1].Period, cycles[i].Period, cycles[i+1].Period
+			if withinFactor(prev, c, MaxPeriodFactor) && withinFactor(c, next, MaxPeriodFactor) {
+				sum += math.Abs(c - (prev+c+next)/3)
+				n++
+			}
+		}
+	}
+	return percentOf(sum, n, meanPeriod(runs))
+}
+
+// shimmerLocal returns local shimmer in percent over consecutive amplitudes
+func shimmerLocal(runs [][]glottalCycle) (float64, bool) {
+	sum, n := 0.0, 0
+	for _, cycles := range runs {
+		for i := 0; i+1 < len(cycles); i++ {
+			if withinFactor(cycles[i].Amplitude, cycles[i+1].Amplitude, MaxAmplitudeFactor) {
+				sum += math.Abs(cycles[i].Amplitude - cycles[i+1].Amplitude)
+				n++
+			}
+		}
+	}
+	return percentOf(sum, n, meanAmplitude(runs))
+}
+
+// shimmerAPQ11 returns the 11-point amplitude perturbation quotient in percent
+func shimmerAPQ11(runs [][]glottalCycle) (float64, bool) {
+	sum, n := 0.0, 0
+	for _, cycles := range runs {
+		for i := 5; i+5 < len(cycles); i++ {
+			avg := 0.0
+			for k := i - 5; k <= i+5; k++ {
+				avg += cycles[k].Amplitude
+			}
+			avg /= 11
+			sum += math.Abs(cycles[i].Amplitude - avg)
+			n++
+		}
+	}
+	return percentOf(sum, n, meanAmplitude(runs))
+}
+
+// harmonicsToNoise returns the mean HNR in dB over voiced frames, from the
+// normalized autocorrelation at the pitch period. It reports false when no
+// frame could be measured.
+func harmonicsToNoise(samples []float64, sampleRate int, pitch *PitchTrack) (float64, bool) {
+	sum := 0.0
+	n := 0
+
+	for _, f := range pitch.Frames {
+		if !f.Voiced {
+			continue
+		}
+
+		lag := int(float64(sampleRate)/f.F0 + 0.5)
+		window := HNRPeriods * lag
+		start := int(f.Time*float64(sampleRate)) - (window+lag)/2
+		if start < 0 || start+window+lag+1 >= len(samples) {
+			continue
+		}
+
+		// Take the best lag around the period to absorb rounding
+		best := 0.0
+		for l := lag - 1; l <= lag+1; l++ {
+			best = math.Max(best, normalizedAutocorrelation(samples[start:start+window+l], l))
+		}
+
+		r := math.Min(best, 0.999999)
+		if r <= 0 {
+			continue
+		}
+		sum += 10 * math.Log10(r/(1-r))
+		n++
+	}
+
+	return mean(sum, n)
+}
+
+// normalizedAutocorrelation correlates x with itself shifted by lag
+func normalizedAutocorrelation(x []float64, lag int) float64 {
+	var cross, e1, e2 float64
+	for i := 0; i+lag < len(x); i++ {
+		cross += x[i] * x[i+lag]
+		e1 += x[i] * x[i]
+		e2 += x[i+lag] * x[i+lag]
+	}
+	if e1 == 0 || e2 == 0 {
+		return 0
+	}
+	return cross / math.Sqrt(e1*e2)
+}
+
+// refinePeak interpolates the sub-sample position and height of a peak
+func refinePeak(samples []float64, i int, polarity float64) (float64, float64) {
+	if i <= 0 || i >= len(samples)-1 {
+		return float64(i), math.Abs(samples[i])
+	}
+
+	left, centre, right := polarity*samples[i-1], polarity*samples[i], polarity*samples[i+1]
+	denominator := left - 2*centre + right
+	if denominator == 0 {
+		return float64(i), math.Abs(centre)
+	}
+
+	offset := 0.5 * (left - right) / denominator
+	height := centre - 0.25*(left-right)*offset
+	return float64(i) + offset, math.Abs(height)
+}
+
+func argMaxAbs(samples []float64, lo, hi int) int {
+	best, bestValue := -1, 0.0
+	for i := lo; i < hi && i < len(samples); i++ {
+		if v := math.Abs(samples[i]); v > bestValue {
+			best, bestValue = i, v
+		}
+	}
+	return best
+}
+
+func argMaxSigned(samples []float64, lo, hi int, polarity float64) int {
+	best, bestValue := -1, 0.0
+	for i := lo; i < hi && i < len(samples); i++ {
+		if v := polarity * samples[i]; v > bestValue {
+			best, bestValue = i, v
+		}
+	}
+	return best
+}
+
+// withinFactor reports whether a and b are within factor of each other
+func withinFactor(a, b, factor float64) bool {
+	if a <= 0 || b <= 0 {
+		return false
+	}
+	return math.Max(a, b)/math.Min(a, b) <= factor
+}
+
+// mean returns sum / n, reporting false when nothing was summed
+func mean(sum float64, n int) (float64, bool) {
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// percentOf returns the mean of n summed deviations as a percentage of ref,
+// reporting false when nothing was summed or ref is zero
+func percentOf(sum float64, n int, ref float64) (float64, bool) {
+	v, ok := mean(sum, n)
+	if !ok || ref == 0 {
+		return 0, false
+	}
+	return 100 * v / ref, true
+}
+
+// measured returns a pointer to v, or nil when it was not measured
+func measured(v float64, ok bool) *float64 {
+	if !ok {
+		return nil
+	}
+	return &v
+}
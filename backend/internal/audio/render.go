@@ -0,0 +1,209 @@
+package audio
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/cmplx"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mjibson/go-dsp/fft"
+)
+
+const (
+	WaveformPeaksPerSecond = 100   // Peak resolution of the cached waveform
+	WaveformMaxPeaks       = 10000 // Long recordings are bucketed more coarsely
+
+	SpectrogramFFTSize    = 2048   // ~46 ms window at 44.1 kHz
+	SpectrogramMaxColumns = 2000   // Image width cap
+	SpectrogramHeight     = 256    // Frequency rows
+	SpectrogramMaxHz      = 8000.0 // Voice energy above this is not shown
+	SpectrogramRangeDB    = 90.0   // Dynamic range mapped onto the colour scale
+
+	ProcessedPrefix = "processed" // Storage key prefix of transcoded audio and renderings
+)
+
+// Waveform is a min/max peak envelope of a recording
+type Waveform struct {
+	SampleRate     int       `json:"sample_rate"`
+	Duration       float64   `json:"duration"`
+	PeaksPerSecond float64   `json:"peaks_per_second"`
+	Min            []float64 `json:"min"`
+	Max            []float64 `json:"max"`
+}
+
+// WAVKeyFor returns the storage key of the WAV transcoded from the upload under key
+func WAVKeyFor(key string) string {
+	return path.Join(ProcessedPrefix, baseName(key)+".wav")
+}
+
+// WaveformKeyFor returns the storage key of the cached waveform peaks for the upload under key
+func WaveformKeyFor(key string) string {
+	return path.Join(ProcessedPrefix, baseName(key)+".waveform.json")
+}
+
+// SpectrogramKeyFor returns the storage key of the cached spectrogram image for the upload under key
+func SpectrogramKeyFor(key string) string {
+	return path.Join(ProcessedPrefix, baseName(key)+".spectrogram.png")
+}
+
+func baseName(key string) string {
+	base := path.Base(key)
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// ComputeWaveform buckets samples into min/max peak pairs
+func ComputeWaveform(samples []float64, sampleRate int) *Waveform {
+	duration := float64(len(samples)) / float64(sampleRate)
+
+	buckets := int(math.Ceil(duration * WaveformPeaksPerSecond))
+	if buckets > WaveformMaxPeaks {
+		buckets = WaveformMaxPeaks
+	}
+	if buckets > len(samples) {
+		buckets = len(samples)
+	}
+
+	waveform := &Waveform{
+		SampleRate: sampleRate,
+		Duration:   duration,
+		Min:        make([]float64, buckets),
+		Max:        make([]float64, buckets),
+	}
+	if buckets == 0 {
+		return waveform
+	}
+	waveform.PeaksPerSecond = float64(buckets) / duration
+
+	for b := 0; b < buckets; b++ {
+		start := b * len(samples) / buckets
+		end := (b + 1) * len(samples) / buckets
+
+		lo, hi := 0.0, 0.0
+		for _, s := range samples[start:end] {
+			lo = math.Min(lo, s)
+			hi = math.Max(hi, s)
+		}
+		waveform.Min[b] = roundTo(lo, 4)
+		waveform.Max[b] = roundTo(hi, 4)
+	}
+
+	return waveform
+}
+
+// RenderSpectrogram draws a log-magnitude spectrogram of samples, with time on
+// the x axis and 0 to SpectrogramMaxHz on the y axis (low frequencies at the bottom)
+func RenderSpectrogram(samples []float64, sampleRate int) image.Image {
+	columns := 0
+	hop := SpectrogramFFTSize / 4
+	if len(samples) >= SpectrogramFFTSize {
+		columns = (len(samples)-SpectrogramFFTSize)/hop + 1
+		if columns > SpectrogramMaxColumns {
+			columns = SpectrogramMaxColumns
+			hop = (len(samples) - SpectrogramFFTSize) / (columns - 1)
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, max(columns, 1), SpectrogramHeight))
+	if columns == 0 {
+		return img
+	}
+
+	maxBin := int(SpectrogramMaxHz * SpectrogramFFTSize / float64(sampleRate))
+	if maxBin > SpectrogramFFTSize/2 {
+		maxBin = SpectrogramFFTSize / 2
+	}
+
+	// Magnitudes in dB, one column per frame
+	levels := make([][]float64, columns)
+	peak := math.Inf(-1)
+	for col := 0; col < columns; col++ {
+		start := col * hop
+		spectrum := fft.FFTReal(applyHammingWindow(samples[start : start+SpectrogramFFTSize]))
+
+		levels[col] = make([]float64, SpectrogramHeight)
+		for row := 0; row < SpectrogramHeight; row++ {
+			// Average the bins that fall into this row
+			lo := row * maxBin / SpectrogramHeight
+			hi := max((row+1)*maxBin/SpectrogramHeight, lo+1)
+			power := 0.0
+			for bin := lo; bin < hi; bin++ {
+				m := cmplx.Abs(spectrum[bin])
+				power += m * m
+			}
+			db := 10 * math.Log10(power/float64(hi-lo)+1e-12)
+			levels[col][row] = db
+			peak = math.Max(peak, db)
+		}
+	}
+
+	for col, column := range levels {
+		for row, db := range column {
+			level := 1 + (db-peak)/SpectrogramRangeDB
+			img.Set(col, SpectrogramHeight-1-row, heatColor(level))
+		}
+	}
+
+	return img
+}
+
+// RenderVisuals writes the waveform peaks as JSON to waveformPath and the
+// spectrogram as PNG to spectrogramPath
+func RenderVisuals(waveformPath, spectrogramPath string, samples []float64, sampleRate int) error {
+	waveform, err := json.Marshal(ComputeWaveform(samples, sampleRate))
+	if err != nil {
+		return fmt.Errorf("failed to encode waveform: %w", err)
+	}
+	if err := os.WriteFile(waveformPath, waveform, 0644); err != nil {
+		return fmt.Errorf("failed to write waveform: %w", err)
+	}
+
+	file, err := os.Create(spectrogramPath)
+	if err != nil {
+		return fmt.Errorf("failed to create spectrogram: %w", err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, RenderSpectrogram(samples, sampleRate)); err != nil {
+		return fmt.Errorf("failed to encode spectrogram: %w", err)
+	}
+
+	return nil
+}
+
+// heatColor maps a level in [0, 1] onto a dark-blue to yellow colour ramp
+func heatColor(level float64) color.RGBA {
+	stops := []color.RGBA{
+		{0x0d, 0x08, 0x87, 0xff},
+		{0x7e, 0x03, 0xa8, 0xff},
+		{0xcc, 0x47, 0x78, 0xff},
+		{0xf8, 0x95, 0x40, 0xff},
+		{0xf0, 0xf9, 0x21, 0xff},
+	}
+
+	level = clamp01(level)
+	pos := level * float64(len(stops)-1)
+	i := int(pos)
+	if i >= len(stops)-1 {
+		return stops[len(stops)-1]
+	}
+
+	t := pos - float64(i)
+	a, b := stops[i], stops[i+1]
+	return color.RGBA{
+		R: uint8(float64(a.R) + t*(float64(b.R)-float64(a.R))),
+		G: uint8(float64(a.G) + t*(float64(b.G)-float64(a.G))),
+		B: uint8(float64(a.B) + t*(float64(b.B)-float64(a.B))),
+		A: 0xff,
+	}
+}
+
+func roundTo(v float64, digits int) float64 {
+	scale := math.Pow(10, float64(digits))
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,145 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// MinLoudnessDB is reported for digital silence
+const MinLoudnessDB = -100.0
+
+// StreamFrame is one analysis frame produced by a PitchStream
+type StreamFrame struct {
+	Time       float64 // Frame centre in seconds since the stream started
+	F0         float64 // Fundamental frequency in Hz, 0 when unvoiced
+	Confidence float64 // Periodicity confidence in [0, 1]
+	Voiced     bool
+	LoudnessDB float64 // RMS level in dBFS
+}
+
+// PitchStream is an incremental version of TrackPitch for live audio. Samples
+// are fed in as they arrive and frames are emitted as soon as enough audio
+// has been buffered, using the same framing and YIN settings as TrackPitch.
+type PitchStream struct {
+	sampleRate int
+	frameSize  int
+	hopSize    int
+	yin        *yinAnalyzer
+	pending    []float64
+	position   int64 // Stream offset of pending[0] in samples
+}
+
+// NewPitchStream creates a stream for mono audio at sampleRate. minHz and
+// maxHz bound the lags searched in each frame.
+func NewPitchStream(sampleRate int, minHz, maxHz float64) *PitchStream {
+	minTau := int(math.Floor(float64(sampleRate) / maxHz))
+	maxTau := int(math.Ceil(float64(sampleRate) / minHz))
+	if minTau < 2 {
+		minTau = 2
+	}
+
+	frameSize := nextPowerOfTwo(2 * maxTau)
+	hopSize := int(float64(sampleRate) * PitchHopSeconds)
+	if hopSize < 1 {
+		hopSize = 1
+	}
+
+	return &PitchStream{
+		sampleRate: sampleRate,
+		frameSize:  frameSize,
+		hopSize:    hopSize,
+		yin:        newYINAnalyzer(frameSize, minTau, maxTau),
+	}
+}
+
+// HopSeconds returns the time between emitted frames
+func (s *PitchStream) HopSeconds() float64 {
+	return float64(s.hopSize) / float64(s.sampleRate)
+}
+
+// LatencySeconds returns how much audio must be buffered before a frame is emitted
+func (s *PitchStream) LatencySeconds() float64 {
+	return float64(s.frameSize) / float64(s.sampleRate)
+}
+
+// Write appends samples and returns the frames that became complete
+func (s *PitchStream) Write(samples []float64) []StreamFrame {
+	s.pending = append(s.pending, samples...)
+
+	var frames []StreamFrame
+	start := 0
+	for start+s.frameSize <= len(s.pending) {
+		frame := s.pending[start : start+s.frameSize]
+		f0, confidence := s.yin.analyze(frame, float64(s.sampleRate))
+
+		level := rms(frame)
+		voiced := f0 > 0 && level >= SilenceRMS
+		if !voiced {
+			f0 = 0
+		}
+
+		frames = append(frames, StreamFrame{
+			Time:       (float64(s.position+int64(start)) + float64(s.frameSize)/2) / float64(s.sampleRate),
+			F0:         f0,
+			Confidence: confidence,
+			Voiced:     voiced,
+			LoudnessDB: loudnessDB(level),
+		})
+		start += s.hopSize
+	}
+
+	// Keep the unconsumed tail; copy so the buffer does not grow without bound
+	if start > 0 {
+		s.pending = append(s.pending[:0], s.pending[start:]...)
+		s.position += int64(start)
+	}
+
+	return frames
+}
+
+// loudnessDB converts an RMS level to dBFS
+func loudnessDB(level float64) float64 {
+	if level <= 0 {
+		return MinLoudnessDB
+	}
+	return math.Max(MinLoudnessDB, 20*math.Log10(level))
+}
+
+// PCM sample encodings accepted from live clients
+const (
+	PCMFloat32 = "f32" // 32-bit little-endian IEEE float
+	PCMInt16   = "s16" // 16-bit little-endian signed integer
+)
+
+// DecodePCM converts little-endian mono PCM bytes into samples in [-1, 1].
+// Non-finite float samples decode as silence.
+func DecodePCM(data []byte, encoding string) ([]float64, error) {
+	switch encoding {
+	case PCMFloat32:
+		if len(data)%4 != 0 {
+			return nil, fmt.Errorf("f32 PCM length %d is not a multiple of 4", len(data))
+		}
+		samples := make([]float64, len(data)/4)
+		for i := range samples {
+			v := float64(math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:])))
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				// Min and Max pass NaN through, and it would poison every measure
+				continue
+			}
+			samples[i] = math.Max(-1, math.Min(1, v))
+		}
+		return samples, nil
+	case PCMInt16:
+		if len(data)%2 != 0 {
+			return nil, fmt.Errorf("s16 PCM length %d is not a multiple of 2", len(data))
+		}
+		samples := make([]float64, len(data)/2)
+		for i := range samples {
+			samples[i] = float64(int16(binary.LittleEndian.Uint16(data[i*2:]))) / 32768
+		}
+		return samples, nil
+	default:
+		return nil, fmt.Errorf("unsupported PCM encoding %q", encoding)
+	}
+}
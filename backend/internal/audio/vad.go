@@ -0,0 +1,207 @@
+package audio
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	VADFrameSeconds      = 0.02  // 20 ms analysis window
+	VADHopSeconds        = 0.01  // 10 ms between frames
+	VADNoiseMarginDB     = 12.0  // Speech must be this far above the estimated noise floor
+	VADDynamicRangeDB    = 20.0  // Frames this far below the loud frames still count as speech
+	VADMinSpeechDB       = -50.0 // Frames quieter than this are always silent
+	VADUnvoicedCrossings = 6000  // Zero crossings per second above which loud frames count as unvoiced (~3 kHz)
+	VADMinSegmentSeconds = 0.06  // Shorter runs are absorbed into their neighbours
+	VADMaxPauseSeconds   = 0.25  // Silent gaps inside speech shorter than this are bridged
+)
+
+// SegmentKind classifies a region of a recording
+type SegmentKind string
+
+const (
+	SegmentVoiced   SegmentKind = "voiced"   // Periodic speech or singing
+	SegmentUnvoiced SegmentKind = "unvoiced" // Fricatives, breath and other noisy speech
+	SegmentSilence  SegmentKind = "silence"
+)
+
+// Segment is a contiguous region of one kind, in seconds
+type Segment struct {
+	Start float64
+	End   float64
+	Kind  SegmentKind
+}
+
+// Duration returns the length of the segment in seconds
+func (s Segment) Duration() float64 {
+	return s.End - s.Start
+}
+
+// IsSpeech reports whether the segment contains voice activity
+func (s Segment) IsSpeech() bool {
+	return s.Kind != SegmentSilence
+}
+
+// VADResult holds the segmentation of a recording
+type VADResult struct {
+	Segments      []Segment
+	ActiveSeconds float64 // Total length of voiced and unvoiced segments
+	SpeechStart   float64 // Start of the first speech segment
+	SpeechEnd     float64 // End of the last speech segment
+}
+
+// IsSpeechAt reports whether time t (seconds) falls inside a speech segment
+func (v *VADResult) IsSpeechAt(t float64) bool {
+	i := sort.Search(len(v.Segments), func(i int) bool { return v.Segments[i].End > t })
+	return i < len(v.Segments) && v.Segments[i].Start <= t && v.Segments[i].IsSpeech()
+}
+
+// DetectVoiceActivity segments samples into voiced, unvoiced and silent regions
+// using short-time energy against an adaptive noise floor and zero-crossing rate
+func DetectVoiceActivity(samples []float64, sampleRate int) *VADResult {
+	frameSize := int(VADFrameSeconds * float64(sampleRate))
+	hopSize := int(VADHopSeconds * float64(sampleRate))
+	if frameSize < 1 || hopSize < 1 || len(samples) < frameSize {
+		return summarizeSegments(nil)
+	}
+
+	// Short-time energy (dBFS) and zero-crossing rate per frame
+	var energies, zcrs []float64
+	for start := 0; start+frameSize <= len(samples); start += hopSize {
+		frame := samples[start : start+frameSize]
+		energies = append(energies, 20*math.Log10(rms(frame)+1e-10))
+		zcrs = append(zcrs, zeroCrossingRate(frame))
+	}
+
+	// The quietest decile approximates the background noise level. Capping the
+	// threshold below the loud frames keeps recordings without pauses from
+	// being classified as all noise.
+	sorted := append([]float64(nil), energies...)
+	sort.Float64s(sorted)
+	threshold := math.Min(percentile(sorted, 10)+VADNoiseMarginDB, percentile(sorted, 90)-VADDynamicRangeDB)
+	threshold = math.Max(threshold, VADMinSpeechDB)
+
+	labels := make([]SegmentKind, len(energies))
+	for i := range energies {
+		switch {
+		case energies[i] < threshold:
+			labels[i] = SegmentSilence
+		case zcrs[i]*float64(sampleRate) > VADUnvoicedCrossings:
+			labels[i] = SegmentUnvoiced
+		default:
+			labels[i] = SegmentVoiced
+		}
+	}
+
+	hop := float64(hopSize) / float64(sampleRate)
+	segments := labelsToSegments(labels, hop)
+	segments = absorbShortSegments(segments, VADMinSegmentSeconds)
+	segments = bridgePauses(segments, VADMaxPauseSeconds)
+
+	// The last frame extends one window past its start
+	if len(segments) > 0 {
+		segments[len(segments)-1].End = float64(len(samples)) / float64(sampleRate)
+	}
+
+	return summarizeSegments(segments)
+}
+
+// GatePitch marks pitch frames outside speech as unvoiced and recomputes the summary
+func GatePitch(track *PitchTrack, vad *VADResult) {
+	for i := range track.Frames {
+		if track.Frames[i].Voiced && !vad.IsSpeechAt(track.Frames[i].Time) {
+			track.Frames[i].Voiced = false
+			track.Frames[i].F0 = 0
+		}
+	}
+	track.Summary = summarizePitch(track.Frames)
+}
+
+// zeroCrossingRate returns the fraction of adjacent samples that change sign
+func zeroCrossingRate(frame []float64) float64 {
+	if len(frame) < 2 {
+		return 0
+	}
+
+	crossings := 0
+	for i := 1; i < len(frame); i++ {
+		if (frame[i-1] >= 0) != (frame[i] >= 0) {
+			crossings++
+		}
+	}
+
+	return float64(crossings) / float64(len(frame)-1)
+}
+
+// labelsToSegments collapses runs of identical frame labels into segments
+func labelsToSegments(labels []SegmentKind, hop float64) []Segment {
+	var segments []Segment
+	for i, label := range labels {
+		t := float64(i) * hop
+		if len(segments) > 0 && segments[len(segments)-1].Kind == label {
+			segments[len(segments)-1].End = t + hop
+			continue
+		}
+		segments = append(segments, Segment{Start: t, End: t + hop, Kind: label})
+	}
+	return segments
+}
+
+// absorbShortSegments merges segments shorter than minSeconds into the preceding one
+func absorbShortSegments(segments []Segment, minSeconds float64) []Segment {
+	var merged []Segment
+	for _, seg := range segments {
+		if len(merged) > 0 && seg.Duration() < minSeconds {
+			merged[len(merged)-1].End = seg.End
+			continue
+		}
+		if len(merged) > 0 && merged[len(merged)-1].Kind == seg.Kind {
+			merged[len(merged)-1].End = seg.End
+			continue
+		}
+		merged = append(merged, seg)
+	}
+	return merged
+}
+
+// bridgePauses relabels short silences between two speech segments as unvoiced
+// speech so that brief breaths do not split an utterance
+func bridgePauses(segments []Segment, maxPause float64) []Segment {
+	for i := 1; i+1 < len(segments); i++ {
+		seg := &segments[i]
+		if seg.Kind == SegmentSilence && seg.Duration() < maxPause &&
+			segments[i-1].IsSpeech() && segments[i+1].IsSpeech() {
+			seg.Kind = SegmentUnvoiced
+		}
+	}
+
+	// Re-merge neighbours that now share a kind
+	var merged []Segment
+	for _, seg := range segments {
+		if len(merged) > 0 && merged[len(merged)-1].Kind == seg.Kind {
+			merged[len(merged)-1].End = seg.End
+			continue
+		}
+		merged = append(merged, seg)
+	}
+	return merged
+}
+
+func summarizeSegments(segments []Segment) *VADResult {
+	result := &VADResult{Segments: segments}
+
+	first := true
+	for _, seg := range segments {
+		if !seg.IsSpeech() {
+			continue
+		}
+		if first {
+			result.SpeechStart = seg.Start
+			first = false
+		}
+		result.SpeechEnd = seg.End
+		result.ActiveSeconds += seg.Duration()
+	}
+
+	return result
+}
@@ -0,0 +1,194 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Upload validation error codes
+const (
+	ErrCodeUnknownFormat         = "unknown_format"
+	ErrCodeFormatMismatch        = "format_mismatch"
+	ErrCodeUnreadableAudio       = "unreadable_audio"
+	ErrCodeUnsupportedCodec      = "unsupported_codec"
+	ErrCodeUnsupportedSampleRate = "unsupported_sample_rate"
+	ErrCodeUnsupportedChannels   = "unsupported_channels"
+)
+
+const (
+	MinUploadSampleRate = 8000  // Telephone quality; anything lower cannot carry formants
+	MaxUploadSampleRate = 96000 // Higher rates add nothing for voice analysis
+	MaxUploadChannels   = 2
+)
+
+// Format is an accepted audio container
+type Format struct {
+	Name         string
+	ContentType  string   // Canonical MIME type used when storing the upload
+	ContentTypes []string // MIME types clients may declare for it
+	Extensions   []string // Accepted filename extensions; the first is canonical
+	Codecs       []string // ffprobe codec names accepted inside the container
+}
+
+// Formats lists the containers accepted for upload
+var Formats = []Format{
+	{
+		Name:         "webm",
+		ContentType:  "audio/webm",
+		ContentTypes: []string{"audio/webm", "video/webm", "audio/x-matroska", "video/x-matroska"},
+		Extensions:   []string{".webm", ".weba", ".mka", ".mkv"},
+		Codecs:       []string{"opus", "vorbis"},
+	},
+	{
+		Name:         "mp4",
+		ContentType:  "audio/mp4",
+		ContentTypes: []string{"audio/mp4", "audio/x-m4a", "audio/m4a", "video/mp4", "audio/aac"},
+		Extensions:   []string{".m4a", ".mp4"},
+		Codecs:       []string{"aac", "alac", "opus"},
+	},
+	{
+		Name:         "wav",
+		ContentType:  "audio/wav",
+		ContentTypes: []string{"audio/wav", "audio/wave", "audio/x-wav", "audio/vnd.wave"},
+		Extensions:   []string{".wav", ".wave"},
+		Codecs:       []string{"pcm_u8", "pcm_s16le", "pcm_s24le", "pcm_s32le", "pcm_f32le", "pcm_f64le"},
+	},
+	{
+		Name:         "mp3",
+		ContentType:  "audio/mpeg",
+		ContentTypes: []string{"audio/mpeg", "audio/mp3", "audio/mpeg3", "audio/x-mpeg-3"},
+		Extensions:   []string{".mp3"},
+		Codecs:       []string{"mp3"},
+	},
+	{
+		Name:         "ogg",
+		ContentType:  "audio/ogg",
+		ContentTypes: []string{"audio/ogg", "application/ogg", "audio/opus"},
+		Extensions:   []string{".ogg", ".oga", ".opus"},
+		Codecs:       []string{"opus", "vorbis", "flac"},
+	},
+}
+
+// SniffLength is how many leading bytes SniffFormat needs
+const SniffLength = 12
+
+// ValidationError explains why an upload was rejected
+type ValidationError struct {
+	Code    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func validationErrorf(code, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// SniffFormat identifies the container from its magic bytes
+func SniffFormat(header []byte) (*Format, bool) {
+	name := ""
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1a, 0x45, 0xdf, 0xa3}): // EBML
+		name = "webm"
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		name = "mp4"
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WAVE":
+		name = "wav"
+	case bytes.HasPrefix(header, []byte("OggS")):
+		name = "ogg"
+	case bytes.HasPrefix(header, []byte("ID3")), isMPEGAudioFrame(header):
+		name = "mp3"
+	default:
+		return nil, false
+	}
+
+	for i := range Formats {
+		if Formats[i].Name == name {
+			return &Formats[i], true
+		}
+	}
+	return nil, false
+}
+
+// isMPEGAudioFrame reports whether header starts with an MPEG audio frame
+// sync. ADTS AAC shares the sync word but has layer bits of zero.
+func isMPEGAudioFrame(header []byte) bool {
+	if len(header) < 2 {
+		return false
+	}
+	sync := binary.BigEndian.Uint16(header[:2])
+	return sync&0xffe0 == 0xffe0 && sync&0x0006 != 0
+}
+
+// StreamInfo describes the first audio stream of a file
+type StreamInfo struct {
+	Codec      string
+	SampleRate int
+	Channels   int
+}
+
+// ValidateUpload checks an uploaded file against its declared filename and
+// content type. The container is sniffed from its magic bytes and must agree
+// with any declared extension or type; the audio stream must use a codec the
+// container allows, with a supported sample rate and channel count.
+// Rejections are returned as *ValidationError.
+func ValidateUpload(ctx context.Context, inputPath, filename, contentType string) (*Format, *StreamInfo, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := make([]byte, SniffLength)
+	n, err := io.ReadFull(file, header)
+	file.Close()
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, nil, err
+	}
+
+	format, ok := SniffFormat(header[:n])
+	if !ok {
+		return nil, nil, validationErrorf(ErrCodeUnknownFormat,
+			"file is not a supported audio format (WebM, MP4/M4A, WAV, MP3 or Ogg)")
+	}
+
+	if ext := strings.ToLower(filepath.Ext(filename)); ext != "" && !slices.Contains(format.Extensions, ext) {
+		return nil, nil, validationErrorf(ErrCodeFormatMismatch,
+			"file extension %s does not match its %s content", ext, format.Name)
+	}
+
+	if declared, _, err := mime.ParseMediaType(contentType); err == nil &&
+		declared != "application/octet-stream" && !slices.Contains(format.ContentTypes, declared) {
+		return nil, nil, validationErrorf(ErrCodeFormatMismatch,
+			"declared content type %s does not match its %s content", declared, format.Name)
+	}
+
+	stream, err := probeWithDecoders(ctx, inputPath)
+	if err != nil {
+		return nil, nil, validationErrorf(ErrCodeUnreadableAudio, "audio stream could not be read: %v", err)
+	}
+
+	if !slices.Contains(format.Codecs, stream.Codec) {
+		return nil, nil, validationErrorf(ErrCodeUnsupportedCodec,
+			"codec %s is not supported in %s files", stream.Codec, format.Name)
+	}
+	if stream.SampleRate < MinUploadSampleRate || stream.SampleRate > MaxUploadSampleRate {
+		return nil, nil, validationErrorf(ErrCodeUnsupportedSampleRate,
+			"sample rate %d Hz is outside the supported range of %d-%d Hz",
+			stream.SampleRate, MinUploadSampleRate, MaxUploadSampleRate)
+	}
+	if stream.Channels < 1 || stream.Channels > MaxUploadChannels {
+		return nil, nil, validationErrorf(ErrCodeUnsupportedChannels,
+			"%d audio channels are not supported (maximum %d)", stream.Channels, MaxUploadChannels)
+	}
+
+	return format, stream, nil
+}
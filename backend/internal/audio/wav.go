@@ -0,0 +1,174 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/mjibson/go-dsp/wav"
+)
+
+// readWAV decodes a whole WAV file into mono samples in the range [-1, 1]
+func readWAV(wavPath string) ([]float64, int, error) {
+	file, err := os.Open(wavPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open WAV file: %w", err)
+	}
+	defer file.Close()
+
+	wavData, err := wav.New(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to parse WAV file: %w", err)
+	}
+
+	channels := int(wavData.NumChannels)
+	if channels < 1 {
+		return nil, 0, fmt.Errorf("invalid channel count: %d", channels)
+	}
+
+	if wavData.Samples == 0 {
+		return []float64{}, int(wavData.SampleRate), nil
+	}
+
+	raw, err := wavData.ReadSamples(wavData.Samples)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read WAV samples: %w", err)
+	}
+
+	// go-dsp maps integer PCM to [0, 1]; convert to signed samples ourselves
+	var interleaved []float64
+	switch data := raw.(type) {
+	case []uint8:
+		interleaved = make([]float64, len(data))
+		for i, v := range data {
+			interleaved[i] = (float64(v) - 128) / 128
+		}
+	case []int16:
+		interleaved = make([]float64, len(data))
+		for i, v := range data {
+			interleaved[i] = float64(v) / 32768
+		}
+	case []float32:
+		interleaved = make([]float64, len(data))
+		for i, v := range data {
+			interleaved[i] = float64(v)
+		}
+	default:
+		return nil, 0, fmt.Errorf("unsupported WAV sample type %T", raw)
+	}
+
+	return downmix(interleaved, channels), int(wavData.SampleRate), nil
+}
+
+// downmix averages interleaved channels into a single mono channel
+func downmix(interleaved []float64, channels int) []float64 {
+	if channels == 1 {
+		return interleaved
+	}
+
+	mono := make([]float64, len(interleaved)/channels)
+	for i := range mono {
+		sum := 0.0
+		for ch := 0; ch < channels; ch++ {
+			sum += interleaved[i*channels+ch]
+		}
+		mono[i] = sum / float64(channels)
+	}
+
+	return mono
+}
+
+// rms returns the root mean square level of samples
+func rms(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, s := range samples {
+		sum += s * s
+	}
+
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+// WAVWriter writes mono 16-bit PCM to a WAV file. The header sizes are
+// filled in by Close.
+type WAVWriter struct {
+	file       *os.File
+	sampleRate int
+	dataBytes  int64
+	buf        []byte
+}
+
+// CreateWAV creates a WAV file at wavPath for mono audio at sampleRate
+func CreateWAV(wavPath string, sampleRate int) (*WAVWriter, error) {
+	file, err := os.Create(wavPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WAV file: %w", err)
+	}
+
+	w := &WAVWriter{file: file, sampleRate: sampleRate}
+	if err := w.writeHeader(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// WriteSamples appends samples in [-1, 1]
+func (w *WAVWriter) WriteSamples(samples []float64) error {
+	w.buf = w.buf[:0]
+	for _, s := range samples {
+		v := int16(math.Round(math.Max(-1, math.Min(1, s)) * 32767))
+		w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(v))
+	}
+
+	n, err := w.file.Write(w.buf)
+	w.dataBytes += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write WAV samples: %w", err)
+	}
+	return nil
+}
+
+// Duration returns the length of the audio written so far in seconds
+func (w *WAVWriter) Duration() float64 {
+	return float64(w.dataBytes/2) / float64(w.sampleRate)
+}
+
+// Close fills in the header sizes and closes the file
+func (w *WAVWriter) Close() error {
+	if _, err := w.file.Seek(0, 0); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.writeHeader(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// writeHeader writes a canonical 44-byte PCM header for the data written so far
+func (w *WAVWriter) writeHeader() error {
+	header := make([]byte, 0, 44)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(36+w.dataBytes))
+	header = append(header, "WAVEfmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16) // fmt chunk size
+	header = binary.LittleEndian.AppendUint16(header, 1)  // PCM
+	header = binary.LittleEndian.AppendUint16(header, 1)  // Mono
+	header = binary.LittleEndian.AppendUint32(header, uint32(w.sampleRate))
+	header = binary.LittleEndian.AppendUint32(header, uint32(w.sampleRate*2)) // Byte rate
+	header = binary.LittleEndian.AppendUint16(header, 2)                      // Block align
+	header = binary.LittleEndian.AppendUint16(header, 16)                     // Bits per sample
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(w.dataBytes))
+
+	if _, err := w.file.Write(header); err != nil {
+		return fmt.Errorf("failed to write WAV header: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package gamification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	_ "time/tzdata" // Resolve user timezones without relying on the host's zoneinfo
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Sources of XP events
+const (
+	SourceRecording = "recording_processed"
+	SourceSession   = "session_completed"
+)
+
+const (
+	RecordingBaseXP      = 10
+	RecordingMaxSpeechXP = 10 // Cap on the speaking-time bonus of one recording
+	SecondsPerSpeechXP   = 10
+	MaxScoreXP           = 10 // Bonus for a perfect exercise score
+	SessionBaseXP        = 5
+	SessionMaxMinuteXP   = 30 // Cap on the practice-time bonus of one session
+	MinSessionSeconds    = 60 // Shorter sessions without exercises earn nothing
+)
+
+// RecordingXP is the XP for a processed recording with activeSeconds of
+// speech. Recordings without speech are not practice and earn nothing.
+func RecordingXP(activeSeconds float64) int {
+	if activeSeconds <= 0 {
+		return 0
+	}
+	return RecordingBaseXP + min(int(activeSeconds/SecondsPerSpeechXP), RecordingMaxSpeechXP)
+}
+
+// ScoreXP is the bonus for a recording that scored score (0-100) against its
+// exercise
+func ScoreXP(score int) int {
+	return min(max(score, 0), 100) * MaxScoreXP / 100
+}
+
+// SessionXP is the XP for completing a session of durationSeconds of practice
+// with the given number of exercises
+func SessionXP(durationSeconds, exercises int) int {
+	if durationSeconds < MinSessionSeconds && exercises == 0 {
+		return 0
+	}
+	return SessionBaseXP + min(durationSeconds/60, SessionMaxMinuteXP)
+}
+
+// Event is an activity that earns XP. Source and SourceID identify it, so
+// awarding the same event twice has no effect.
+type Event struct {
+	UserID     string
+	Source     string
+	SourceID   string
+	XP         int
+	OccurredAt time.Time // Decides the streak day, in the user's timezone
+}
+
+// Outcome is the state of a user after an award
+type Outcome struct {
+	XP            int      `json:"xp"` // Awarded by this event
+	Progress      Progress `json:"progress"`
+	LeveledUp     bool     `json:"leveled_up"`
+	StreakCount   int      `json:"streak_count"`
+	StreakFreezes int      `json:"streak_freezes"`
+}
+
+// LoadLocation resolves a user's timezone, falling back to UTC for names
+// that are not in the tz database
+func LoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ValidTimezone reports whether name is an IANA timezone name
+func ValidTimezone(name string) bool {
+	_, err := time.LoadLocation(name)
+	return err == nil && name != "" && name != "Local"
+}
+
+// Award credits ev to its user inside tx: it adds the XP, recomputes the
+// level and advances the daily streak. It returns nil when the event was
+// already awarded or earns no XP.
+func Award(ctx context.Context, tx pgx.Tx, ev Event) (*Outcome, error) {
+	if ev.XP <= 0 {
+		return nil, nil
+	}
+
+	var eventID string
+	err := tx.QueryRow(ctx,
+		`INSERT INTO xp_events (user_id, source, source_id, xp, occurred_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (source, source_id) DO NOTHING
+		 RETURNING id`,
+		ev.UserID, ev.Source, ev.SourceID, ev.XP, ev.OccurredAt).Scan(&eventID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record XP event: %w", err)
+	}
+
+	var (
+		totalXP, level int
+		timezone       string
+		streak         Streak
+	)
+	err = tx.QueryRow(ctx,
+		`SELECT total_xp, level, streak_count, last_practice_date, streak_freezes, timezone
+		 FROM users WHERE id = $1 FOR UPDATE`,
+		ev.UserID).Scan(&totalXP, &level, &streak.Count, &streak.LastDay, &streak.Freezes, &timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user progress: %w", err)
+	}
+
+	totalXP += ev.XP
+	newLevel := LevelForXP(totalXP)
+	streak = streak.Practice(Day(ev.OccurredAt, LoadLocation(timezone)))
+
+	_, err = tx.Exec(ctx,
+		`UPDATE users
+		 SET total_xp = $1, level = $2, streak_count = $3, last_practice_date = $4, streak_freezes = $5,
+		     updated_at = NOW()
+		 WHERE id = $6`,
+		totalXP, newLevel, streak.Count, streak.LastDay, streak.Freezes, ev.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user progress: %w", err)
+	}
+
+	return &Outcome{
+		XP:            ev.XP,
+		Progress:      ProgressFor(totalXP),
+		LeveledUp:     newLevel > level,
+		StreakCount:   streak.Count,
+		StreakFreezes: streak.Freezes,
+	}, nil
+}
@@ -0,0 +1,162 @@
+package gamification
+
+import (
+	"testing"
+	"time"
+)
+
+var testDay = time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+// daysAfter returns the day n days after testDay
+func daysAfter(n int) time.Time {
+	return testDay.AddDate(0, 0, n)
+}
+
+func TestStreakPractice(t *testing.T) {
+	last := testDay
+
+	tests := []struct {
+		name        string
+		streak      Streak
+		gap         int // Days from the last practice to this one
+		wantCount   int
+		wantFreezes int
+		wantLastDay time.Time
+	}{
+		{"first practice", Streak{}, 0, 1, 0, testDay},
+		{"same day", Streak{Count: 5, LastDay: &last}, 0, 5, 0, testDay},
+		{"earlier day", Streak{Count: 5, LastDay: &last, Freezes: 1}, -1, 5, 1, testDay},
+		{"next day", Streak{Count: 5, LastDay: &last}, 1, 6, 0, daysAfter(1)},
+		{"one missed day, one freeze", Streak{Count: 5, LastDay: &last, Freezes: 1}, 2, 6, 0, daysAfter(2)},
+		{"one missed day, no freeze", Streak{Count: 5, LastDay: &last}, 2, 1, 0, daysAfter(2)},
+		{"two missed days, two freezes", Streak{Count: 5, LastDay: &last, Freezes: 2}, 3, 6, 0, daysAfter(3)},
+		{"two missed days, one freeze", Streak{Count: 5, LastDay: &last, Freezes: 1}, 3, 1, 1, daysAfter(3)},
+		{"freeze earned", Streak{Count: StreakFreezeEvery - 1, LastDay: &last}, 1, StreakFreezeEvery, 1, daysAfter(1)},
+		{"freeze earned on a covered day", Streak{Count: StreakFreezeEvery - 1, LastDay: &last, Freezes: 1}, 2, StreakFreezeEvery, 1, daysAfter(2)},
+		{"freezes capped", Streak{Count: 2*StreakFreezeEvery - 1, LastDay: &last, Freezes: MaxStreakFreezes}, 1, 2 * StreakFreezeEvery, MaxStreakFreezes, daysAfter(1)},
+		{"no freeze between multiples", Streak{Count: StreakFreezeEvery, LastDay: &last, Freezes: 1}, 1, StreakFreezeEvery + 1, 1, daysAfter(1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.streak.Practice(daysAfter(tt.gap))
+			if got.Count != tt.wantCount || got.Freezes != tt.wantFreezes {
+				t.Errorf("got count %d, freezes %d; want %d, %d", got.Count, got.Freezes, tt.wantCount, tt.wantFreezes)
+			}
+			if got.LastDay == nil || !got.LastDay.Equal(tt.wantLastDay) {
+				t.Errorf("last day = %v, want %v", got.LastDay, tt.wantLastDay)
+			}
+		})
+	}
+}
+
+func TestStreakCurrent(t *testing.T) {
+	last := testDay
+
+	tests := []struct {
+		name   string
+		streak Streak
+		today  int // Days from the last practice to today
+		want   int
+	}{
+		{"never practiced", Streak{}, 0, 0},
+		{"practiced today", Streak{Count: 4, LastDay: &last}, 0, 4},
+		{"practiced yesterday", Streak{Count: 4, LastDay: &last}, 1, 4},
+		{"one missed day, no freeze", Streak{Count: 4, LastDay: &last}, 2, 0},
+		{"one missed day, one freeze", Streak{Count: 4, LastDay: &last, Freezes: 1}, 2, 4},
+		{"two missed days, one freeze", Streak{Count: 4, LastDay: &last, Freezes: 1}, 3, 0},
+		{"two missed days, two freezes", Streak{Count: 4, LastDay: &last, Freezes: 2}, 3, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.streak.Current(daysAfter(tt.today)); got != tt.want {
+				t.Errorf("Current = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDay(t *testing.T) {
+	instant := time.Date(2026, 3, 10, 23, 30, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		loc  *time.Location
+		want time.Time
+	}{
+		{time.UTC, testDay},
+		{time.FixedZone("UTC-5", -5*3600), testDay},
+		{time.FixedZone("UTC+9", 9*3600), daysAfter(1)},
+	} {
+		if got := Day(instant, tt.loc); !got.Equal(tt.want) {
+			t.Errorf("Day in %s = %v, want %v", tt.loc, got, tt.want)
+		}
+	}
+}
+
+func TestXPForLevel(t *testing.T) {
+	tests := []struct {
+		level, want int
+	}{
+		{0, 0},
+		{1, 0},
+		{2, 100},
+		{3, 250},
+		{4, 475},
+		{5, 813},
+		{MaxLevel + 10, XPForLevel(MaxLevel)},
+	}
+	for _, tt := range tests {
+		if got := XPForLevel(tt.level); got != tt.want {
+			t.Errorf("XPForLevel(%d) = %d, want %d", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestLevelForXP(t *testing.T) {
+	tests := []struct {
+		xp, want int
+	}{
+		{0, 1},
+		{99, 1},
+		{100, 2},
+		{249, 2},
+		{250, 3},
+		{812, 4},
+		{813, 5},
+		{XPForLevel(MaxLevel) * 2, MaxLevel},
+	}
+	for _, tt := range tests {
+		if got := LevelForXP(tt.xp); got != tt.want {
+			t.Errorf("LevelForXP(%d) = %d, want %d", tt.xp, got, tt.want)
+		}
+	}
+
+	// Every level starts exactly at its XP threshold
+	for level := 2; level <= MaxLevel; level++ {
+		xp := XPForLevel(level)
+		if got := LevelForXP(xp); got != level {
+			t.Errorf("LevelForXP(%d) = %d, want %d", xp, got, level)
+		}
+		if got := LevelForXP(xp - 1); got != level-1 {
+			t.Errorf("LevelForXP(%d) = %d, want %d", xp-1, got, level-1)
+		}
+	}
+}
+
+func TestProgressFor(t *testing.T) {
+	top := XPForLevel(MaxLevel)
+	tests := []struct {
+		xp   int
+		want Progress
+	}{
+		{0, Progress{Level: 1, TotalXP: 0, LevelXP: 0, NextLevelXP: 100, XPToNextLevel: 100}},
+		{100, Progress{Level: 2, TotalXP: 100, LevelXP: 0, NextLevelXP: 150, XPToNextLevel: 150}},
+		{300, Progress{Level: 3, TotalXP: 300, LevelXP: 50, NextLevelXP: 225, XPToNextLevel: 175}},
+		{top + 5, Progress{Level: MaxLevel, TotalXP: top + 5, LevelXP: 5}},
+	}
+	for _, tt := range tests {
+		if got := ProgressFor(tt.xp); got != tt.want {
+			t.Errorf("ProgressFor(%d) = %+v, want %+v", tt.xp, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package gamification
+
+import "math"
+
+const (
+	LevelBaseXP = 100 // XP needed to go from level 1 to 2
+	LevelGrowth = 1.5 // Each level needs this much more XP than the one before
+	MaxLevel    = 50
+)
+
+// XPForLevel returns the total XP needed to reach level. The XP per level
+// grows exponentially, so early levels come quickly and later ones take
+// sustained practice.
+func XPForLevel(level int) int {
+	if level <= 1 {
+		return 0
+	}
+	level = min(level, MaxLevel)
+	return int(math.Round(LevelBaseXP * (math.Pow(LevelGrowth, float64(level-1)) - 1) / (LevelGrowth - 1)))
+}
+
+// LevelForXP returns the level reached with totalXP
+func LevelForXP(totalXP int) int {
+	level := 1
+	for level < MaxLevel && XPForLevel(level+1) <= totalXP {
+		level++
+	}
+	return level
+}
+
+// Progress is how far a user is through their current level
+type Progress struct {
+	Level         int `json:"level"`
+	TotalXP       int `json:"total_xp"`
+	LevelXP       int `json:"level_xp"`                // XP earned since reaching Level
+	NextLevelXP   int `json:"next_level_xp,omitempty"` // XP Level spans; 0 at MaxLevel
+	XPToNextLevel int `json:"xp_to_next_level"`
+}
+
+// ProgressFor describes totalXP relative to its level
+func ProgressFor(totalXP int) Progress {
+	level := LevelForXP(totalXP)
+	p := Progress{Level: level, TotalXP: totalXP, LevelXP: totalXP - XPForLevel(level)}
+	if level < MaxLevel {
+		p.NextLevelXP = XPForLevel(level+1) - XPForLevel(level)
+		p.XPToNextLevel = XPForLevel(level+1) - totalXP
+	}
+	return p
+}
@@ -0,0 +1,68 @@
+package gamification
+
+import "time"
+
+const (
+	StreakFreezeEvery = 7 // A freeze token is earned every this many streak days
+	MaxStreakFreezes  = 2
+)
+
+// Streak is a run of consecutive practice days in the user's timezone
+type Streak struct {
+	Count   int
+	LastDay *time.Time // Calendar day of the last practice, as midnight UTC
+	Freezes int        // Tokens that each cover one missed day
+}
+
+// Day returns the calendar day of t in loc, as midnight UTC
+func Day(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// Practice returns the streak after practicing on day. Practicing again on
+// the same day, or on a day before the last one, changes nothing. Missed days
+// are covered by freeze tokens when there are enough of them; otherwise the
+// streak starts over. Every StreakFreezeEvery days of streak earns a token.
+func (s Streak) Practice(day time.Time) Streak {
+	if s.LastDay != nil {
+		gap := daysBetween(*s.LastDay, day)
+		switch {
+		case gap <= 0:
+			return s
+		case gap == 1:
+			s.Count++
+		case gap-1 <= s.Freezes:
+			s.Freezes -= gap - 1
+			s.Count++
+		default:
+			s.Count = 1
+		}
+	} else {
+		s.Count = 1
+	}
+
+	if s.Count%StreakFreezeEvery == 0 {
+		s.Freezes = min(s.Freezes+1, MaxStreakFreezes)
+	}
+	s.LastDay = &day
+	return s
+}
+
+// Current returns the streak count as of today. A streak whose missed days
+// can no longer be covered by freeze tokens has lapsed, even though it is
+// only reset in storage on the next practice.
+func (s Streak) Current(today time.Time) int {
+	if s.LastDay == nil {
+		return 0
+	}
+	// Today itself is not missed yet; there is still time to practice
+	if missed := daysBetween(*s.LastDay, today) - 1; missed > s.Freezes {
+		return 0
+	}
+	return s.Count
+}
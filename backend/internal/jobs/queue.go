@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusDead    = "dead" // Exhausted its retries; kept for inspection
+
+	DefaultMaxAttempts = 5
+)
+
+// Job is a unit of background work claimed from the jobs table
+type Job struct {
+	ID          string
+	Kind        string
+	Payload     json.RawMessage
+	Attempts    int
+	MaxAttempts int
+}
+
+// Decode unmarshals the job payload into v
+func (j *Job) Decode(v any) error {
+	if err := json.Unmarshal(j.Payload, v); err != nil {
+		return fmt.Errorf("invalid payload for job %s: %w", j.ID, err)
+	}
+	return nil
+}
+
+// HandlerFunc runs a job. Returning an error schedules a retry unless the
+// error is wrapped with Permanent.
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job goes straight to the dead state
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Execer is satisfied by both the connection pool and a transaction, so jobs
+// can be enqueued atomically with the rows they refer to
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]HandlerFunc{}
+)
+
+// Register associates a handler with a job kind
+func Register(kind string, handler HandlerFunc) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[kind] = handler
+}
+
+func handlerFor(kind string) (HandlerFunc, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	h, ok := handlers[kind]
+	return h, ok
+}
+
+// Enqueue adds a job to the queue. When dedupeKey is not empty and a queued
+// or running job with the same key exists, the call is a no-op.
+func Enqueue(ctx context.Context, db Execer, kind, dedupeKey string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode job payload: %w", err)
+	}
+
+	var key *string
+	if dedupeKey != "" {
+		key = &dedupeKey
+	}
+
+	_, err = db.Exec(ctx,
+		`INSERT INTO jobs (kind, payload, dedupe_key, max_attempts)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (dedupe_key) WHERE dedupe_key IS NOT NULL AND status IN ('queued', 'running')
+		 DO NOTHING`,
+		kind, data, key, DefaultMaxAttempts)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue %s job: %w", kind, err)
+	}
+
+	return nil
+}
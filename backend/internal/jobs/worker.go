@@ -0,0 +1,254 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"voice-training-app/internal/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Config controls the worker pool
+type Config struct {
+	Workers      int           // Number of concurrent workers
+	PollInterval time.Duration // Idle wait between claim attempts
+	BaseBackoff  time.Duration // Delay before the first retry; doubles per attempt
+	MaxBackoff   time.Duration // Upper bound on the retry delay
+	JobTimeout   time.Duration // Deadline passed to each handler
+	LockTimeout  time.Duration // Running jobs older than this are considered abandoned
+}
+
+// DefaultConfig returns the settings used when no environment overrides are set
+func DefaultConfig() Config {
+	return Config{
+		Workers:      2,
+		PollInterval: time.Second,
+		BaseBackoff:  10 * time.Second,
+		MaxBackoff:   30 * time.Minute,
+		JobTimeout:   10 * time.Minute,
+		LockTimeout:  15 * time.Minute,
+	}
+}
+
+// ConfigFromEnv reads JOB_WORKERS and JOB_TIMEOUT_SECONDS on top of DefaultConfig
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+
+	if v, err := strconv.Atoi(os.Getenv("JOB_WORKERS")); err == nil && v > 0 {
+		cfg.Workers = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("JOB_TIMEOUT_SECONDS")); err == nil && v > 0 {
+		cfg.JobTimeout = time.Duration(v) * time.Second
+		if cfg.LockTimeout < cfg.JobTimeout {
+			cfg.LockTimeout = cfg.JobTimeout + 5*time.Minute
+		}
+	}
+
+	return cfg
+}
+
+// Pool is a bounded set of workers draining the jobs table
+type Pool struct {
+	cfg    Config
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewPool(cfg Config) *Pool {
+	return &Pool{cfg: cfg}
+}
+
+// Start releases abandoned jobs and launches the workers. Workers stop when
+// ctx is cancelled or Stop is called.
+func (p *Pool) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	if n, err := p.releaseAbandoned(ctx); err != nil {
+		log.Printf("Failed to release abandoned jobs: %v", err)
+	} else if n > 0 {
+		log.Printf("Released %d abandoned jobs", n)
+	}
+
+	for i := 0; i < p.cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.work(ctx)
+	}
+
+	// Periodically release jobs held by workers that died mid-run
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.cfg.LockTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := p.releaseAbandoned(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("Failed to release abandoned jobs: %v", err)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Job pool started with %d workers", p.cfg.Workers)
+}
+
+// Stop cancels the workers and waits for in-flight jobs to return
+func (p *Pool) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+func (p *Pool) work(ctx context.Context) {
+	defer p.wg.Done()
+
+	for {
+		job, err := p.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to claim job: %v", err)
+		}
+
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.cfg.PollInterval):
+			}
+			continue
+		}
+
+		p.run(ctx, job)
+	}
+}
+
+// claim locks the next runnable job, skipping rows other workers hold
+func (p *Pool) claim(ctx context.Context) (*Job, error) {
+	var job Job
+	err := database.DB.QueryRow(ctx,
+		`UPDATE jobs
+		 SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		 WHERE id = (
+		   SELECT id FROM jobs
+		   WHERE status = 'queued' AND run_at <= NOW()
+		   ORDER BY run_at
+		   FOR UPDATE SKIP LOCKED
+		   LIMIT 1
+		 )
+		 RETURNING id, kind, payload, attempts, max_attempts`).Scan(
+		&job.ID, &job.Kind, &job.Payload, &job.Attempts, &job.MaxAttempts)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (p *Pool) run(ctx context.Context, job *Job) {
+	handler, ok := handlerFor(job.Kind)
+	if !ok {
+		p.fail(job, fmt.Errorf("no handler registered for job kind %q", job.Kind), true)
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, p.cfg.JobTimeout)
+	err := runHandler(jobCtx, handler, job)
+	cancel()
+
+	if err != nil {
+		log.Printf("Job %s (%s) attempt %d/%d failed: %v", job.ID, job.Kind, job.Attempts, job.MaxAttempts, err)
+		p.fail(job, err, IsPermanent(err))
+		return
+	}
+
+	// Use a fresh context so shutdown does not lose the completion
+	_, err = database.DB.Exec(context.Background(),
+		`UPDATE jobs SET status = 'done', locked_at = NULL, last_error = NULL, updated_at = NOW() WHERE id = $1`,
+		job.ID)
+	if err != nil {
+		log.Printf("Failed to mark job %s done: %v", job.ID, err)
+	}
+}
+
+// runHandler calls handler, converting a panic into an error
+func runHandler(ctx context.Context, handler HandlerFunc, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// fail schedules a retry with exponential backoff, or moves the job to the
+// dead state once it has used all its attempts
+func (p *Pool) fail(job *Job, jobErr error, permanent bool) {
+	ctx := context.Background()
+
+	if permanent || job.Attempts >= job.MaxAttempts {
+		_, err := database.DB.Exec(ctx,
+			`UPDATE jobs SET status = 'dead', locked_at = NULL, last_error = $1, updated_at = NOW() WHERE id = $2`,
+			jobErr.Error(), job.ID)
+		if err != nil {
+			log.Printf("Failed to mark job %s dead: %v", job.ID, err)
+		}
+		log.Printf("Job %s (%s) moved to dead letter: %v", job.ID, job.Kind, jobErr)
+		return
+	}
+
+	delay := p.backoff(job.Attempts)
+	_, err := database.DB.Exec(ctx,
+		`UPDATE jobs
+		 SET status = 'queued', locked_at = NULL, last_error = $1,
+		     run_at = NOW() + $2 * INTERVAL '1 millisecond', updated_at = NOW()
+		 WHERE id = $3`,
+		jobErr.Error(), delay.Milliseconds(), job.ID)
+	if err != nil {
+		log.Printf("Failed to reschedule job %s: %v", job.ID, err)
+	}
+}
+
+// backoff returns the retry delay after the given attempt, with up to 20% jitter
+func (p *Pool) backoff(attempt int) time.Duration {
+	delay := p.cfg.BaseBackoff
+	for i := 1; i < attempt && delay < p.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.cfg.MaxBackoff {
+		delay = p.cfg.MaxBackoff
+	}
+
+	jitter := time.Duration(rand.Int64N(int64(delay)/5 + 1))
+	return delay + jitter
+}
+
+// releaseAbandoned re-queues running jobs whose lock has expired. Jobs that
+// have used all their attempts move to the dead state instead, so a job that
+// crashes or hangs its worker every time is not claimed forever.
+func (p *Pool) releaseAbandoned(ctx context.Context) (int64, error) {
+	tag, err := database.DB.Exec(ctx,
+		`UPDATE jobs
+		 SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'queued' END,
+		     last_error = CASE WHEN attempts >= max_attempts THEN 'lock expired on the last attempt' ELSE last_error END,
+		     locked_at = NULL, updated_at = NOW()
+		 WHERE status = 'running' AND locked_at < NOW() - $1 * INTERVAL '1 second'`,
+		int64(p.cfg.LockTimeout.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
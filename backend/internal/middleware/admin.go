@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"voice-training-app/internal/database"
+	"voice-training-app/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminRequired lets only admins through. It must run after AuthRequired.
+// The flag is read from the database on every request, so revoking it takes
+// effect without waiting for tokens to expire.
+func AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var isAdmin bool
+		err := database.DB.QueryRow(context.Background(),
+			`SELECT is_admin FROM users WHERE id = $1`, c.GetString("user_id")).Scan(&isAdmin)
+		if err != nil || !isAdmin {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Admin access required",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Exercise kinds; the kind decides how a recording of the exercise is judged
+const (
+	ExerciseKindSustain   = "sustain"   // Hold a steady note
+	ExerciseKindGlide     = "glide"     // Slide through a range, e.g. a siren
+	ExerciseKindSpeech    = "speech"    // Read or speak connected text
+	ExerciseKindBreathing = "breathing" // Controlled airflow, little or no voicing
+)
+
+// ValidExerciseKind reports whether kind is a known exercise kind
+func ValidExerciseKind(kind string) bool {
+	switch kind {
+	case ExerciseKindSustain, ExerciseKindGlide, ExerciseKindSpeech, ExerciseKindBreathing:
+		return true
+	}
+	return false
+}
+
+type ExerciseCategory struct {
+	ID          string    `json:"id" db:"id"`
+	Slug        string    `json:"slug" db:"slug"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	SortOrder   int       `json:"sort_order" db:"sort_order"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type Exercise struct {
+	ID                string    `json:"id" db:"id"`
+	CategoryID        string    `json:"category_id" db:"category_id"`
+	CategorySlug      string    `json:"category" db:"category_slug"`
+	Slug              string    `json:"slug" db:"slug"`
+	Title             string    `json:"title" db:"title"`
+	Description       string    `json:"description" db:"description"`
+	Instructions      string    `json:"instructions" db:"instructions"`
+	Kind              string    `json:"kind" db:"kind"`
+	Difficulty        int       `json:"difficulty" db:"difficulty"` // 1 (easiest) to 5
+	TargetPitchMinHz  *float64  `json:"target_pitch_min_hz,omitempty" db:"target_pitch_min_hz"`
+	TargetPitchMaxHz  *float64  `json:"target_pitch_max_hz,omitempty" db:"target_pitch_max_hz"`
+	TargetDurationSec *float64  `json:"target_duration_sec,omitempty" db:"target_duration_sec"`
+	Repetitions       int       `json:"repetitions" db:"repetitions"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CategoryRequest struct {
+	Slug        string `json:"slug" binding:"required,max=50"`
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description"`
+	SortOrder   int    `json:"sort_order"`
+}
+
+type ExerciseRequest struct {
+	CategoryID        string   `json:"category_id" binding:"required,uuid"`
+	Slug              string   `json:"slug" binding:"required,max=80"`
+	Title             string   `json:"title" binding:"required,max=200"`
+	Description       string   `json:"description"`
+	Instructions      string   `json:"instructions"`
+	Kind              string   `json:"kind" binding:"required"`
+	Difficulty        int      `json:"difficulty" binding:"required,min=1,max=5"`
+	TargetPitchMinHz  *float64 `json:"target_pitch_min_hz"`
+	TargetPitchMaxHz  *float64 `json:"target_pitch_max_hz"`
+	TargetDurationSec *float64 `json:"target_duration_sec" binding:"omitempty,gt=0"`
+	Repetitions       int      `json:"repetitions" binding:"omitempty,min=1"`
+}
@@ -0,0 +1,20 @@
+package models
+
+type PitchPoint struct {
+	Time       float64  `json:"t"`
+	F0Hz       *float64 `json:"f0_hz"`
+	Confidence float64  `json:"confidence"`
+	Voiced     bool     `json:"voiced"`
+	F1Hz       *float64 `json:"f1_hz,omitempty"`
+	F2Hz       *float64 `json:"f2_hz,omitempty"`
+	F3Hz       *float64 `json:"f3_hz,omitempty"`
+}
+
+type PitchContour struct {
+	RecordingID string       `json:"recording_id"`
+	From        float64      `json:"from"`
+	To          float64      `json:"to"`
+	TotalFrames int          `json:"total_frames"`
+	Downsampled bool         `json:"downsampled"`
+	Points      []PitchPoint `json:"points"`
+}
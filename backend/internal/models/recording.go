@@ -1,15 +1,84 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+// Recording processing states
+const (
+	RecordingStatusPending    = "pending"
+	RecordingStatusProcessing = "processing"
+	RecordingStatusDone       = "done"
+	RecordingStatusFailed     = "failed"
+)
+
+// ValidRecordingStatus reports whether status is a known processing state
+func ValidRecordingStatus(status string) bool {
+	switch status {
+	case RecordingStatusPending, RecordingStatusProcessing, RecordingStatusDone, RecordingStatusFailed:
+		return true
+	}
+	return false
+}
 
 type Recording struct {
-	ID               string    `json:"id" db:"id"`
-	UserID           string    `json:"user_id" db:"user_id"`
-	FilePath         string    `json:"file_path" db:"file_path"`
-	OriginalFilename string    `json:"original_filename" db:"original_filename"`
-	Duration         float64   `json:"duration" db:"duration"`
-	FileSize         int64     `json:"file_size" db:"file_size"`
-	PitchHz          *float64  `json:"pitch_hz,omitempty" db:"pitch_hz"`
-	CreatedAt        time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+	ID               string          `json:"id" db:"id"`
+	UserID           string          `json:"user_id" db:"user_id"`
+	SessionID        *string         `json:"session_id,omitempty" db:"session_id"`
+	ExerciseID       *string         `json:"exercise_id,omitempty" db:"exercise_id"`
+	FilePath         string          `json:"file_path" db:"file_path"`
+	OriginalFilename string          `json:"original_filename" db:"original_filename"`
+	Title            *string         `json:"title,omitempty" db:"title"`
+	Notes            *string         `json:"notes,omitempty" db:"notes"`
+	Tags             []string        `json:"tags,omitempty" db:"-"` // Loaded from recording_tags, sorted by name
+	Duration         float64         `json:"duration" db:"duration"`
+	FileSize         int64           `json:"file_size" db:"file_size"`
+	PitchHz          *float64        `json:"pitch_hz,omitempty" db:"pitch_hz"`
+	PitchMeanHz      *float64        `json:"pitch_mean_hz,omitempty" db:"pitch_mean_hz"`
+	PitchMinHz       *float64        `json:"pitch_min_hz,omitempty" db:"pitch_min_hz"`
+	PitchMaxHz       *float64        `json:"pitch_max_hz,omitempty" db:"pitch_max_hz"`
+	PitchP10Hz       *float64        `json:"pitch_p10_hz,omitempty" db:"pitch_p10_hz"`
+	PitchP90Hz       *float64        `json:"pitch_p90_hz,omitempty" db:"pitch_p90_hz"`
+	PitchStdDevHz    *float64        `json:"pitch_stddev_hz,omitempty" db:"pitch_stddev_hz"`
+	VoicedRatio      *float64        `json:"voiced_ratio,omitempty" db:"voiced_ratio"`
+	F1Hz             *float64        `json:"f1_hz,omitempty" db:"f1_hz"`
+	F2Hz             *float64        `json:"f2_hz,omitempty" db:"f2_hz"`
+	F3Hz             *float64        `json:"f3_hz,omitempty" db:"f3_hz"`
+	JitterLocalPct   *float64        `json:"jitter_local_pct,omitempty" db:"jitter_local_pct"`
+	JitterRAPPct     *float64        `json:"jitter_rap_pct,omitempty" db:"jitter_rap_pct"`
+	ShimmerLocalPct  *float64        `json:"shimmer_local_pct,omitempty" db:"shimmer_local_pct"`
+	ShimmerAPQ11Pct  *float64        `json:"shimmer_apq11_pct,omitempty" db:"shimmer_apq11_pct"`
+	HNRDb            *float64        `json:"hnr_db,omitempty" db:"hnr_db"`
+	ActiveSeconds    *float64        `json:"active_seconds,omitempty" db:"active_seconds"`
+	SpeechStartSec   *float64        `json:"speech_start_sec,omitempty" db:"speech_start_sec"`
+	SpeechEndSec     *float64        `json:"speech_end_sec,omitempty" db:"speech_end_sec"`
+	ExerciseScore    *int            `json:"exercise_score,omitempty" db:"exercise_score"`   // 0-100
+	ScoreBreakdown   json.RawMessage `json:"score_breakdown,omitempty" db:"score_breakdown"` // Per-criterion scores
+	Status           string          `json:"status" db:"status"`
+	FailureReason    *string         `json:"failure_reason,omitempty" db:"failure_reason"`
+	FailureDetail    *string         `json:"-" db:"failure_detail"` // ffmpeg/ffprobe stderr; server-side only
+	ProcessedAt      *time.Time      `json:"processed_at,omitempty" db:"processed_at"`
+	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Kind  string  `json:"kind"`
+}
+
+// UpdateRecordingRequest changes the labels of a recording. Unset fields keep
+// their value; an empty title or notes clears it and tags replaces the whole set.
+type UpdateRecordingRequest struct {
+	Title *string  `json:"title" binding:"omitempty,max=200"`
+	Notes *string  `json:"notes" binding:"omitempty,max=5000"`
+	Tags  []string `json:"tags" binding:"omitempty,max=20,dive,max=50"`
+}
+
+// Tag is a label of a user's recordings
+type Tag struct {
+	Name       string `json:"name"`
+	Recordings int    `json:"recordings"` // Recordings carrying the tag
 }
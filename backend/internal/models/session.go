@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Practice session states
+const (
+	SessionStatusActive    = "active"
+	SessionStatusCompleted = "completed"
+)
+
+// PracticeSession is a stretch of practice that recordings are made in.
+// Duration and ExercisesCompleted are computed by the server on completion.
+type PracticeSession struct {
+	ID                 string     `json:"id" db:"id"`
+	UserID             string     `json:"user_id" db:"user_id"`
+	Status             string     `json:"status" db:"status"`
+	Duration           *int       `json:"duration,omitempty" db:"duration"` // Seconds of active practice
+	ActiveSeconds      float64    `json:"active_seconds" db:"active_seconds"`
+	ExercisesCompleted int        `json:"exercises_completed" db:"exercises_completed"`
+	XPEarned           int        `json:"xp_earned" db:"xp_earned"`
+	LastHeartbeatAt    time.Time  `json:"last_heartbeat_at" db:"last_heartbeat_at"`
+	CompletedAt        *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type AttachRecordingRequest struct {
+	RecordingID string `json:"recording_id" binding:"required,uuid"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// UploadSession is a resumable upload in progress
+type UploadSession struct {
+	ID          string     `json:"id" db:"id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Filename    string     `json:"filename" db:"filename"`
+	ContentType string     `json:"content_type" db:"content_type"`
+	Length      int64      `json:"length" db:"upload_length"`
+	Offset      int64      `json:"offset" db:"upload_offset"`
+	SessionID   *string    `json:"session_id,omitempty" db:"session_id"`
+	ExerciseID  *string    `json:"exercise_id,omitempty" db:"exercise_id"`
+	RecordingID *string    `json:"recording_id,omitempty" db:"recording_id"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
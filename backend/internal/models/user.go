@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type User struct {
 	ID               string    `json:"id"`
@@ -12,11 +15,23 @@ type User struct {
 	LastPracticeDate *string   `json:"last_practice_date,omitempty"`
 	TotalXP          int       `json:"total_xp"`
 	Level            int       `json:"level"`
+	StreakFreezes    int       `json:"streak_freezes"`
+	Timezone         string    `json:"timezone"` // IANA name; streak days follow it
 }
 
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=8"`
+	Timezone string `json:"timezone"`
+}
+
+type UpdateMeRequest struct {
+	Timezone *string `json:"timezone"`
+
+	// Moved to the voice profile; still read so requests that set them are
+	// rejected rather than silently ignored
+	TargetPitchMinHz json.RawMessage `json:"target_pitch_min_hz"`
+	TargetPitchMaxHz json.RawMessage `json:"target_pitch_max_hz"`
 }
 
 type LoginRequest struct {
@@ -28,4 +43,5 @@ type APIResponse struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"` // Machine-readable error code
 }
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Voice training goals
+const (
+	VoiceGoalDeepen      = "deepen"       // Lower the speaking pitch
+	VoiceGoalRaise       = "raise"        // Raise the speaking pitch
+	VoiceGoalRangeExtend = "range_extend" // Widen the usable range
+	VoiceGoalResonance   = "resonance"    // Shift resonance (formants) rather than pitch
+)
+
+// ValidVoiceGoal reports whether goal is a known voice training goal
+func ValidVoiceGoal(goal string) bool {
+	switch goal {
+	case VoiceGoalDeepen, VoiceGoalRaise, VoiceGoalRangeExtend, VoiceGoalResonance:
+		return true
+	}
+	return false
+}
+
+// VoiceProfile is a user's measured baseline, goal and target bands. Every
+// field but the user is optional; a user who never set one has an empty
+// profile.
+type VoiceProfile struct {
+	UserID               string     `json:"user_id" db:"user_id"`
+	Goal                 *string    `json:"goal,omitempty" db:"goal"`
+	BaselineMinHz        *float64   `json:"baseline_min_hz,omitempty" db:"baseline_min_hz"` // 5th percentile of voiced pitch
+	BaselineMedianHz     *float64   `json:"baseline_median_hz,omitempty" db:"baseline_median_hz"`
+	BaselineMaxHz        *float64   `json:"baseline_max_hz,omitempty" db:"baseline_max_hz"` // 95th percentile of voiced pitch
+	BaselineF1Hz         *float64   `json:"baseline_f1_hz,omitempty" db:"baseline_f1_hz"`
+	BaselineF2Hz         *float64   `json:"baseline_f2_hz,omitempty" db:"baseline_f2_hz"`
+	BaselineRecordingIDs []string   `json:"baseline_recording_ids" db:"baseline_recording_ids"`
+	BaselineMeasuredAt   *time.Time `json:"baseline_measured_at,omitempty" db:"baseline_measured_at"`
+	TargetPitchMinHz     *float64   `json:"target_pitch_min_hz,omitempty" db:"target_pitch_min_hz"`
+	TargetPitchMaxHz     *float64   `json:"target_pitch_max_hz,omitempty" db:"target_pitch_max_hz"`
+	TargetF1MinHz        *float64   `json:"target_f1_min_hz,omitempty" db:"target_f1_min_hz"`
+	TargetF1MaxHz        *float64   `json:"target_f1_max_hz,omitempty" db:"target_f1_max_hz"`
+	TargetF2MinHz        *float64   `json:"target_f2_min_hz,omitempty" db:"target_f2_min_hz"`
+	TargetF2MaxHz        *float64   `json:"target_f2_max_hz,omitempty" db:"target_f2_max_hz"`
+	CreatedAt            *time.Time `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt            *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// UpdateVoiceProfileRequest changes a voice profile; unset fields keep their
+// value and each band's bounds are set together
+type UpdateVoiceProfileRequest struct {
+	Goal             *string  `json:"goal"`
+	TargetPitchMinHz *float64 `json:"target_pitch_min_hz"`
+	TargetPitchMaxHz *float64 `json:"target_pitch_max_hz"`
+	TargetF1MinHz    *float64 `json:"target_f1_min_hz"`
+	TargetF1MaxHz    *float64 `json:"target_f1_max_hz"`
+	TargetF2MinHz    *float64 `json:"target_f2_min_hz"`
+	TargetF2MaxHz    *float64 `json:"target_f2_max_hz"`
+}
+
+// VoiceBaselineRequest names the onboarding recordings a baseline is measured from
+type VoiceBaselineRequest struct {
+	RecordingIDs []string `json:"recording_ids" binding:"required,min=1,max=10,dive,uuid"`
+}
@@ -0,0 +1,332 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"voice-training-app/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Bucket sizes
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+const (
+	MaxBuckets      = 400 // Longest report, in buckets
+	MinTrendSamples = 3   // Recordings a trend needs before it is reported
+)
+
+// Trend directions relative to the user's target
+const (
+	DirectionToward   = "toward_target"
+	DirectionAway     = "away_from_target"
+	DirectionInTarget = "in_target"
+	DirectionSteady   = "steady"
+)
+
+// DB is satisfied by both the connection pool and a transaction
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// ValidBucket reports whether bucket is a known bucket size
+func ValidBucket(bucket string) bool {
+	return bucket == BucketDay || bucket == BucketWeek || bucket == BucketMonth
+}
+
+// DefaultFrom returns the first day of the default report ending on to: the
+// last 30 days, or the 12 weeks or 12 months up to and including to's
+func DefaultFrom(bucket string, to time.Time) time.Time {
+	switch bucket {
+	case BucketWeek:
+		return BucketStart(bucket, to).AddDate(0, 0, -7*11)
+	case BucketMonth:
+		return BucketStart(bucket, to).AddDate(0, -11, 0)
+	}
+	return to.AddDate(0, 0, -29)
+}
+
+// BucketStart returns the first day of the bucket that day falls in; weeks
+// start on Monday
+func BucketStart(bucket string, day time.Time) time.Time {
+	switch bucket {
+	case BucketWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case BucketMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	}
+	return day
+}
+
+// BucketCount returns the number of buckets from the bucket of from through
+// the bucket of to
+func BucketCount(bucket string, from, to time.Time) int {
+	from, to = BucketStart(bucket, from), BucketStart(bucket, to)
+	switch bucket {
+	case BucketWeek:
+		return int(to.Sub(from).Hours()/(24*7)) + 1
+	case BucketMonth:
+		return (to.Year()-from.Year())*12 + int(to.Month()-from.Month()) + 1
+	}
+	return int(to.Sub(from).Hours()/24) + 1
+}
+
+// Query selects a report. From and To are local dates in Timezone, both
+// inclusive; From is moved back to the start of its bucket.
+type Query struct {
+	UserID   string
+	Bucket   string
+	From     time.Time
+	To       time.Time
+	Timezone string
+	Profile  *models.VoiceProfile // Decides which way each trend should go
+}
+
+// Bucket holds the activity of one day, week or month. Averages are nil when
+// the bucket has nothing to average.
+type Bucket struct {
+	Start                     string   `json:"start"` // Local date the bucket begins on
+	Recordings                int      `json:"recordings"`
+	Sessions                  int      `json:"sessions"`
+	PracticeMinutes           float64  `json:"practice_minutes"` // Active time of completed sessions
+	CumulativePracticeMinutes float64  `json:"cumulative_practice_minutes"`
+	SpeakingMinutes           float64  `json:"speaking_minutes"` // Detected speech in processed recordings
+	MedianPitchHz             *float64 `json:"median_pitch_hz,omitempty"`
+	MedianF2Hz                *float64 `json:"median_f2_hz,omitempty"`
+	ScoredRecordings          int      `json:"scored_recordings"`
+	MeanExerciseScore         *float64 `json:"mean_exercise_score,omitempty"`
+	MeanJitterLocalPct        *float64 `json:"mean_jitter_local_pct,omitempty"`
+	MeanShimmerLocalPct       *float64 `json:"mean_shimmer_local_pct,omitempty"`
+	MeanHNRDb                 *float64 `json:"mean_hnr_db,omitempty"`
+}
+
+// Trend is a least-squares line through one measure of the recordings in a
+// report
+type Trend struct {
+	SlopePerWeek float64 `json:"slope_per_week"`
+	Current      float64 `json:"current"` // Fitted value at the end of the report
+	Samples      int     `json:"samples"`
+	Direction    string  `json:"direction,omitempty"` // Relative to the target; empty without one
+}
+
+// Report is the progress of a user over a date range
+type Report struct {
+	Bucket   string            `json:"bucket"`
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Timezone string            `json:"timezone"`
+	Buckets  []Bucket          `json:"buckets"`
+	Trends   map[string]*Trend `json:"trends"` // Keyed by measure; absent without enough recordings
+}
+
+// localTime converts a stored UTC timestamp column to local time in the
+// timezone bound to $2
+func localTime(column string) string {
+	return `((` + column + ` AT TIME ZONE 'UTC') AT TIME ZONE $2)`
+}
+
+// Load builds the report selected by q
+func Load(ctx context.Context, db DB, q Query) (*Report, error) {
+	q.From = BucketStart(q.Bucket, q.From)
+	from, to := q.From.Format(time.DateOnly), q.To.Format(time.DateOnly)
+	report := &Report{
+		Bucket:   q.Bucket,
+		From:     from,
+		To:       to,
+		Timezone: q.Timezone,
+		Buckets:  []Bucket{},
+	}
+
+	// Every bucket in the range is listed, with or without activity
+	rows, err := db.Query(ctx,
+		`WITH buckets AS (
+		   SELECT generate_series(date_trunc($5, $3::date::timestamp), $4::date::timestamp,
+		                          ('1 ' || $5)::interval) AS start
+		 ),
+		 recs AS (
+		   SELECT date_trunc($5, `+localTime("created_at")+`) AS start,
+		          COUNT(*) AS recordings,
+		          COALESCE(SUM(active_seconds), 0) / 60 AS speaking_minutes,
+		          percentile_cont(0.5) WITHIN GROUP (ORDER BY pitch_hz) AS median_pitch,
+		          percentile_cont(0.5) WITHIN GROUP (ORDER BY f2_hz) AS median_f2,
+		          COUNT(exercise_score) AS scored,
+		          AVG(exercise_score)::float8 AS score,
+		          AVG(jitter_local_pct) AS jitter,
+		          AVG(shimmer_local_pct) AS shimmer,
+		          AVG(hnr_db) AS hnr
+		   FROM recordings
+		   WHERE user_id = $1 AND status = 'done'
+		     AND `+localTime("created_at")+` >= $3::date AND `+localTime("created_at")+` < $4::date + 1
+		   GROUP BY 1
+		 ),
+		 sess AS (
+		   SELECT date_trunc($5, `+localTime("created_at")+`) AS start,
+		          COUNT(*) AS sessions,
+		          (COALESCE(SUM(duration), 0) / 60.0)::float8 AS practice_minutes
+		   FROM sessions
+		   WHERE user_id = $1 AND status = 'completed'
+		     AND `+localTime("created_at")+` >= $3::date AND `+localTime("created_at")+` < $4::date + 1
+		   GROUP BY 1
+		 )
+		 SELECT to_char(b.start, 'YYYY-MM-DD'),
+		        COALESCE(r.recordings, 0), COALESCE(s.sessions, 0),
+		        COALESCE(s.practice_minutes, 0),
+		        SUM(COALESCE(s.practice_minutes, 0)) OVER (ORDER BY b.start),
+		        COALESCE(r.speaking_minutes, 0), r.median_pitch, r.median_f2,
+		        COALESCE(r.scored, 0), r.score, r.jitter, r.shimmer, r.hnr
+		 FROM buckets b
+		 LEFT JOIN recs r ON r.start = b.start
+		 LEFT JOIN sess s ON s.start = b.start
+		 ORDER BY b.start`,
+		q.UserID, q.Timezone, from, to, q.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load progress buckets: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b Bucket
+		err := rows.Scan(&b.Start, &b.Recordings, &b.Sessions, &b.PracticeMinutes, &b.CumulativePracticeMinutes,
+			&b.SpeakingMinutes, &b.MedianPitchHz, &b.MedianF2Hz,
+			&b.ScoredRecordings, &b.MeanExerciseScore, &b.MeanJitterLocalPct, &b.MeanShimmerLocalPct, &b.MeanHNRDb)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read progress bucket: %w", err)
+		}
+		report.Buckets = append(report.Buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load progress buckets: %w", err)
+	}
+
+	report.Trends, err = loadTrends(ctx, db, q)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Measures a trend is fitted to
+const (
+	TrendPitch         = "median_pitch_hz"
+	TrendF2            = "median_f2_hz"
+	TrendRange         = "pitch_range_semitones" // P10-P90 spread of each recording
+	TrendExerciseScore = "exercise_score"
+)
+
+// loadTrends fits a line through each measure of the recordings in the
+// report against weeks since its first day
+func loadTrends(ctx context.Context, db DB, q Query) (map[string]*Trend, error) {
+	from, to := q.From.Format(time.DateOnly), q.To.Format(time.DateOnly)
+
+	var fits [4]struct {
+		slope, intercept *float64
+		count            int
+	}
+	err := db.QueryRow(ctx,
+		`WITH r AS (
+		   SELECT EXTRACT(EPOCH FROM `+localTime("created_at")+` - $3::date::timestamp) / 604800 AS week,
+		          pitch_hz, f2_hz,
+		          12 * ln(pitch_p90_hz / NULLIF(pitch_p10_hz, 0)) / ln(2) AS range_st,
+		          exercise_score::float8 AS score
+		   FROM recordings
+		   WHERE user_id = $1 AND status = 'done'
+		     AND `+localTime("created_at")+` >= $3::date AND `+localTime("created_at")+` < $4::date + 1
+		 )
+		 SELECT regr_slope(pitch_hz, week), regr_intercept(pitch_hz, week), regr_count(pitch_hz, week),
+		        regr_slope(f2_hz, week), regr_intercept(f2_hz, week), regr_count(f2_hz, week),
+		        regr_slope(range_st, week), regr_intercept(range_st, week), regr_count(range_st, week),
+		        regr_slope(score, week), regr_intercept(score, week), regr_count(score, week)
+		 FROM r`,
+		q.UserID, q.Timezone, from, to).Scan(
+		&fits[0].slope, &fits[0].intercept, &fits[0].count,
+		&fits[1].slope, &fits[1].intercept, &fits[1].count,
+		&fits[2].slope, &fits[2].intercept, &fits[2].count,
+		&fits[3].slope, &fits[3].intercept, &fits[3].count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fit progress trends: %w", err)
+	}
+
+	// The fitted value is read at the end of the last day
+	weeks := q.To.AddDate(0, 0, 1).Sub(q.From).Hours() / (24 * 7)
+	goals := trendGoals(q.Profile)
+
+	trends := map[string]*Trend{}
+	for i, measure := range []string{TrendPitch, TrendF2, TrendRange, TrendExerciseScore} {
+		fit := fits[i]
+		if fit.count < MinTrendSamples || fit.slope == nil || fit.intercept == nil {
+			continue
+		}
+		t := &Trend{
+			SlopePerWeek: *fit.slope,
+			Current:      *fit.intercept + *fit.slope*weeks,
+			Samples:      fit.count,
+		}
+		if g, ok := goals[measure]; ok {
+			t.Direction = g.direction(t)
+		}
+		trends[measure] = t
+	}
+	return trends, nil
+}
+
+// trendGoal is where a measure should go: into a band when one is set,
+// otherwise in the sign of want
+type trendGoal struct {
+	minHz, maxHz *float64
+	want         float64
+}
+
+func (g trendGoal) direction(t *Trend) string {
+	if g.minHz != nil && g.maxHz != nil {
+		switch {
+		case t.Current >= *g.minHz && t.Current <= *g.maxHz:
+			return DirectionInTarget
+		case t.Current < *g.minHz:
+			g.want = 1
+		default:
+			g.want = -1
+		}
+	}
+
+	switch {
+	case t.SlopePerWeek == 0:
+		return DirectionSteady
+	case t.SlopePerWeek*g.want > 0:
+		return DirectionToward
+	}
+	return DirectionAway
+}
+
+// trendGoals derives the goal of each measure from a voice profile's target
+// bands and training goal
+func trendGoals(profile *models.VoiceProfile) map[string]trendGoal {
+	goals := map[string]trendGoal{TrendExerciseScore: {want: 1}}
+	if profile == nil {
+		return goals
+	}
+
+	pitch := trendGoal{minHz: profile.TargetPitchMinHz, maxHz: profile.TargetPitchMaxHz}
+	if profile.Goal != nil {
+		switch *profile.Goal {
+		case models.VoiceGoalDeepen:
+			pitch.want = -1
+		case models.VoiceGoalRaise:
+			pitch.want = 1
+		case models.VoiceGoalRangeExtend:
+			goals[TrendRange] = trendGoal{want: 1}
+		}
+	}
+	if pitch.want != 0 || (pitch.minHz != nil && pitch.maxHz != nil) {
+		goals[TrendPitch] = pitch
+	}
+	if profile.TargetF2MinHz != nil && profile.TargetF2MaxHz != nil {
+		goals[TrendF2] = trendGoal{minHz: profile.TargetF2MinHz, maxHz: profile.TargetF2MaxHz}
+	}
+	return goals
+}
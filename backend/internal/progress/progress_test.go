@@ -0,0 +1,117 @@
+package progress
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestBucketStart(t *testing.T) {
+	tests := []struct {
+		bucket, day, want string
+	}{
+		{BucketDay, "2026-10-18", "2026-10-18"},
+		{BucketWeek, "2026-10-12", "2026-10-12"}, // Monday
+		{BucketWeek, "2026-10-16", "2026-10-12"},
+		{BucketWeek, "2026-10-18", "2026-10-12"}, // Sunday ends the week
+		{BucketWeek, "2026-01-01", "2025-12-29"},
+		{BucketMonth, "2026-10-31", "2026-10-01"},
+		{BucketMonth, "2026-01-01", "2026-01-01"},
+	}
+	for _, tt := range tests {
+		if got := BucketStart(tt.bucket, date(tt.day)); !got.Equal(date(tt.want)) {
+			t.Errorf("BucketStart(%s, %s) = %s, want %s", tt.bucket, tt.day, got.Format(time.DateOnly), tt.want)
+		}
+	}
+}
+
+func TestBucketCount(t *testing.T) {
+	tests := []struct {
+		bucket, from, to string
+		want             int
+	}{
+		{BucketDay, "2026-10-16", "2026-10-16", 1},
+		{BucketDay, "2026-10-01", "2026-10-16", 16},
+		{BucketDay, "2025-12-31", "2026-01-01", 2},
+		{BucketWeek, "2026-10-12", "2026-10-18", 1},
+		{BucketWeek, "2026-10-18", "2026-10-19", 2}, // Sunday to Monday
+		{BucketWeek, "2025-12-31", "2026-01-14", 3},
+		{BucketMonth, "2026-10-01", "2026-10-31", 1},
+		{BucketMonth, "2025-11-20", "2026-02-03", 4},
+		{BucketMonth, "2025-01-31", "2026-01-01", 13},
+	}
+	for _, tt := range tests {
+		if got := BucketCount(tt.bucket, date(tt.from), date(tt.to)); got != tt.want {
+			t.Errorf("BucketCount(%s, %s, %s) = %d, want %d", tt.bucket, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultFrom(t *testing.T) {
+	tests := []struct {
+		bucket, to, want string
+	}{
+		{BucketDay, "2026-10-16", "2026-09-17"},
+		{BucketWeek, "2026-10-12", "2026-07-27"}, // Monday
+		{BucketWeek, "2026-10-18", "2026-07-27"}, // Sunday
+		{BucketMonth, "2026-10-16", "2025-11-01"},
+		{BucketMonth, "2026-03-31", "2025-04-01"},
+		{BucketMonth, "2026-01-15", "2025-02-01"},
+	}
+	for _, tt := range tests {
+		if got := DefaultFrom(tt.bucket, date(tt.to)); !got.Equal(date(tt.want)) {
+			t.Errorf("DefaultFrom(%s, %s) = %s, want %s", tt.bucket, tt.to, got.Format(time.DateOnly), tt.want)
+		}
+	}
+
+	// Whatever day the report ends on, the default spans the same number of buckets
+	wants := map[string]int{BucketDay: 30, BucketWeek: 12, BucketMonth: 12}
+	for to := date("2025-12-01"); to.Before(date("2027-01-01")); to = to.AddDate(0, 0, 1) {
+		for bucket, want := range wants {
+			if got := BucketCount(bucket, DefaultFrom(bucket, to), to); got != want {
+				t.Fatalf("default %s report ending %s has %d buckets, want %d", bucket, to.Format(time.DateOnly), got, want)
+			}
+		}
+	}
+}
+
+func TestTrendGoalDirection(t *testing.T) {
+	minHz, maxHz := 180.0, 220.0
+	band := trendGoal{minHz: &minHz, maxHz: &maxHz}
+
+	tests := []struct {
+		name    string
+		goal    trendGoal
+		current float64
+		slope   float64
+		want    string
+	}{
+		{"inside band", band, 200, 5, DirectionInTarget},
+		{"on band edge", band, 220, 5, DirectionInTarget},
+		{"below band rising", band, 150, 5, DirectionToward},
+		{"below band falling", band, 150, -5, DirectionAway},
+		{"above band falling", band, 250, -5, DirectionToward},
+		{"above band rising", band, 250, 5, DirectionAway},
+		{"outside band flat", band, 150, 0, DirectionSteady},
+		{"band overrides want", trendGoal{minHz: &minHz, maxHz: &maxHz, want: -1}, 150, 5, DirectionToward},
+		{"want up, rising", trendGoal{want: 1}, 0, 2, DirectionToward},
+		{"want up, falling", trendGoal{want: 1}, 0, -2, DirectionAway},
+		{"want down, rising", trendGoal{want: -1}, 0, 2, DirectionAway},
+		{"want up, flat", trendGoal{want: 1}, 0, 0, DirectionSteady},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trend := &Trend{Current: tt.current, SlopePerWeek: tt.slope}
+			if got := tt.goal.direction(trend); got != tt.want {
+				t.Errorf("direction = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package scoring
+
+import (
+	"math"
+	"sort"
+	"voice-training-app/internal/audio"
+	"voice-training-app/internal/models"
+)
+
+const (
+	MaxRunGapSeconds        = 0.1   // Unvoiced gaps shorter than this do not break a held note or glide
+	StableCents             = 20.0  // Pitch spread of a held note that still scores full marks
+	UnstableCents           = 100.0 // Spread at which a held note scores nothing
+	GlideJumpCents          = 100.0 // A step this large between 10 ms frames is a break, not a glide
+	MaxGlideJumpFraction    = 0.1   // Share of broken steps at which a glide scores nothing for smoothness
+	DefaultGlideSemitones   = 12.0  // Span a glide must cover when the exercise has no target band
+	MinRepetitionFraction   = 0.5   // A repetition must last this share of the target duration
+	DefaultSustainSeconds   = 5.0
+	DefaultGlideSeconds     = 3.0
+	DefaultSpeechSeconds    = 10.0
+	DefaultBreathingSeconds = 10.0
+)
+
+// Criteria names
+const (
+	CriterionInBand      = "in_band"     // Share of voiced time inside the target band
+	CriterionSustain     = "sustain"     // Length of the held notes or breaths against the target
+	CriterionStability   = "stability"   // Pitch steadiness of held notes
+	CriterionRange       = "range"       // Span a glide covers
+	CriterionSmoothness  = "smoothness"  // Absence of breaks and jumps in a glide
+	CriterionDuration    = "duration"    // Total speaking or voiced time against the target
+	CriterionRepetitions = "repetitions" // Repetitions performed against those asked for
+	CriterionResonance   = "resonance"   // Share of voiced time with F1 and F2 inside their target bands
+)
+
+// Target is what an exercise asks of a recording
+type Target struct {
+	Kind        string
+	MinHz       *float64 // Target band; nil when the exercise has none
+	MaxHz       *float64
+	F1MinHz     *float64 // Formant bands from the voice profile; nil when unset
+	F1MaxHz     *float64
+	F2MinHz     *float64
+	F2MaxHz     *float64
+	DurationSec *float64
+	Repetitions int
+}
+
+// TargetFor builds the target of an exercise for a voice profile. The pitch
+// band falls back to the profile's target band when the exercise does not set
+// one; the formant bands always come from the profile.
+func TargetFor(e *models.Exercise, profile *models.VoiceProfile) Target {
+	t := Target{
+		Kind:        e.Kind,
+		MinHz:       e.TargetPitchMinHz,
+		MaxHz:       e.TargetPitchMaxHz,
+		DurationSec: e.TargetDurationSec,
+		Repetitions: max(e.Repetitions, 1),
+	}
+	if profile == nil {
+		return t
+	}
+	if t.MinHz == nil && profile.TargetPitchMinHz != nil && profile.TargetPitchMaxHz != nil {
+		t.MinHz, t.MaxHz = profile.TargetPitchMinHz, profile.TargetPitchMaxHz
+	}
+	t.F1MinHz, t.F1MaxHz = profile.TargetF1MinHz, profile.TargetF1MaxHz
+	t.F2MinHz, t.F2MaxHz = profile.TargetF2MinHz, profile.TargetF2MaxHz
+	return t
+}
+
+func (t Target) hasFormantBand() bool {
+	return (t.F1MinHz != nil && t.F1MaxHz != nil) || (t.F2MinHz != nil && t.F2MaxHz != nil)
+}
+
+// Criterion is one scored aspect of a recording
+type Criterion struct {
+	Name   string  `json:"name"`
+	Score  int     `json:"score"`            // 0-100
+	Value  float64 `json:"value"`            // What was measured, in Unit
+	Target float64 `json:"target,omitempty"` // What was asked for, in Unit
+	Unit   string  `json:"unit"`
+}
+
+// Result is the overall score with its per-criterion breakdown
+type Result struct {
+	Score    int         `json:"score"` // 0-100, the mean of the criteria
+	Criteria []Criterion `json:"criteria"`
+}
+
+// Score compares a gated pitch contour, its formants and voice activity with
+// target. The criteria depend on the kind of exercise: held notes are judged
+// on band, sustain length and steadiness, glides on range and smoothness,
+// speech on band and speaking time, and breathing on the length of each
+// breath. Held notes and speech are also judged on resonance when the target
+// has formant bands.
+func Score(target Target, pitch *audio.PitchTrack, formants *audio.FormantTrack, vad *audio.VADResult) Result {
+	var criteria []Criterion
+	hasBand := target.MinHz != nil && target.MaxHz != nil
+
+	switch target.Kind {
+	case models.ExerciseKindSustain:
+		runs := voicedRuns(pitch)
+		goal := targetDuration(target, DefaultSustainSeconds)
+		best := longest(runDurations(runs, pitch.HopSeconds), target.Repetitions)
+		if hasBand {
+			criteria = append(criteria, inBand(pitch, *target.MinHz, *target.MaxHz))
+		}
+		criteria = append(criteria,
+			lengthCriterion(CriterionSustain, best, goal),
+			stability(runs, target.Repetitions))
+		if target.hasFormantBand() {
+			criteria = append(criteria, resonance(pitch, formants, target))
+		}
+		criteria = appendRepetitions(criteria, runDurations(runs, pitch.HopSeconds), goal, target.Repetitions)
+
+	case models.ExerciseKindGlide:
+		runs := voicedRuns(pitch)
+		goal := targetDuration(target, DefaultGlideSeconds)
+		criteria = append(criteria,
+			glideRange(runs, target),
+			smoothness(runs),
+			lengthCriterion(CriterionDuration, []float64{voicedSeconds(pitch)}, goal*float64(target.Repetitions)))
+		criteria = appendRepetitions(criteria, runDurations(runs, pitch.HopSeconds), goal, target.Repetitions)
+
+	case models.ExerciseKindSpeech:
+		goal := targetDuration(target, DefaultSpeechSeconds)
+		if hasBand {
+			criteria = append(criteria, inBand(pitch, *target.MinHz, *target.MaxHz))
+		}
+		if target.hasFormantBand() {
+			criteria = append(criteria, resonance(pitch, formants, target))
+		}
+		criteria = append(criteria, lengthCriterion(CriterionDuration, []float64{vad.ActiveSeconds}, goal))
+
+	case models.ExerciseKindBreathing:
+		// Hissing is unvoiced, so breaths are measured from voice activity rather than pitch
+		var breaths []float64
+		for _, seg := range vad.Segments {
+			if seg.IsSpeech() {
+				breaths = append(breaths, seg.Duration())
+			}
+		}
+		goal := targetDuration(target, DefaultBreathingSeconds)
+		criteria = append(criteria, lengthCriterion(CriterionSustain, longest(breaths, target.Repetitions), goal))
+		criteria = appendRepetitions(criteria, breaths, goal, target.Repetitions)
+	}
+
+	result := Result{Criteria: criteria}
+	if len(criteria) > 0 {
+		total := 0
+		for _, c := range criteria {
+			total += c.Score
+		}
+		result.Score = int(math.Round(float64(total) / float64(len(criteria))))
+	}
+	return result
+}
+
+func targetDuration(target Target, fallback float64) float64 {
+	if target.DurationSec != nil && *target.DurationSec > 0 {
+		return *target.DurationSec
+	}
+	return fallback
+}
+
+// voicedRuns splits the voiced frames of a contour into runs, bridging
+// gaps shorter than MaxRunGapSeconds
+func voicedRuns(pitch *audio.PitchTrack) [][]audio.PitchFrame {
+	maxGap := int(MaxRunGapSeconds / pitch.HopSeconds)
+
+	var runs [][]audio.PitchFrame
+	var run []audio.PitchFrame
+	gap := 0
+	for _, f := range pitch.Frames {
+		if f.Voiced {
+			run = append(run, f)
+			gap = 0
+			continue
+		}
+		gap++
+		if gap > maxGap && len(run) > 0 {
+			runs = append(runs, run)
+			run = nil
+		}
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// runDurations returns the span of each run in seconds
+func runDurations(runs [][]audio.PitchFrame, hop float64) []float64 {
+	durations := make([]float64, len(runs))
+	for i, run := range runs {
+		durations[i] = run[len(run)-1].Time - run[0].Time + hop
+	}
+	return durations
+}
+
+// longest returns the n largest values, largest first
+func longest(values []float64, n int) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+	return sorted[:min(n, len(sorted))]
+}
+
+func voicedSeconds(pitch *audio.PitchTrack) float64 {
+	return float64(pitch.Summary.VoicedFrames) * pitch.HopSeconds
+}
+
+func cents(f, ref float64) float64 {
+	return 1200 * math.Log2(f/ref)
+}
+
+func percent(fraction float64) int {
+	return int(math.Round(100 * math.Max(0, math.Min(1, fraction))))
+}
+
+// inBand scores the share of voiced frames inside [minHz, maxHz]
+func inBand(pitch *audio.PitchTrack, minHz, maxHz float64) Criterion {
+	voiced, inside := 0, 0
+	for _, f := range pitch.Frames {
+		if !f.Voiced {
+			continue
+		}
+		voiced++
+		if f.F0 >= minHz && f.F0 <= maxHz {
+			inside++
+		}
+	}
+
+	share := 0.0
+	if voiced > 0 {
+		share = float64(inside) / float64(voiced)
+	}
+	return Criterion{Name: CriterionInBand, Score: percent(share), Value: 100 * share, Target: 100, Unit: "%"}
+}
+
+// resonance scores the share of voiced frames with formant estimates whose F1
+// and F2 lie inside the target's formant bands; an unset band always matches
+func resonance(pitch *audio.PitchTrack, formants *audio.FormantTrack, target Target) Criterion {
+	within := func(f float64, minHz, maxHz *float64) bool {
+		return minHz == nil || maxHz == nil || (f >= *minHz && f <= *maxHz)
+	}
+
+	measured, inside := 0, 0
+	for i, f := range pitch.Frames {
+		if !f.Voiced || formants == nil || i >= len(formants.Frames) {
+			continue
+		}
+		ff := formants.Frames[i]
+		if ff.F1 == 0 || ff.F2 == 0 {
+			continue
+		}
+		measured++
+		if within(ff.F1, target.F1MinHz, target.F1MaxHz) && within(ff.F2, target.F2MinHz, target.F2MaxHz) {
+			inside++
+		}
+	}
+
+	share := 0.0
+	if measured > 0 {
+		share = float64(inside) / float64(measured)
+	}
+	return Criterion{Name: CriterionResonance, Score: percent(share), Value: 100 * share, Target: 100, Unit: "%"}
+}
+
+// lengthCriterion scores each attempt's length against goal, capped at full
+// marks, and averages over the attempts. Value is the mean attempt length.
+func lengthCriterion(name string, attempts []float64, goal float64) Criterion {
+	c := Criterion{Name: name, Target: goal, Unit: "s"}
+	if len(attempts) == 0 {
+		return c
+	}
+
+	sum, fraction := 0.0, 0.0
+	for _, a := range attempts {
+		sum += a
+		fraction += math.Min(a/goal, 1)
+	}
+	c.Value = sum / float64(len(attempts))
+	c.Score = percent(fraction / float64(len(attempts)))
+	return c
+}
+
+// stability scores the pitch spread of the longest held notes in cents
+func stability(runs [][]audio.PitchFrame, repetitions int) Criterion {
+	c := Criterion{Name: CriterionStability, Target: StableCents, Unit: "cents"}
+
+	runs = append([][]audio.PitchFrame(nil), runs...)
+	sort.Slice(runs, func(i, j int) bool { return len(runs[i]) > len(runs[j]) })
+	runs = runs[:min(repetitions, len(runs))]
+	if len(runs) == 0 {
+		return c
+	}
+
+	spread := 0.0
+	for _, run := range runs {
+		f0 := make([]float64, len(run))
+		for i, f := range run {
+			f0[i] = f.F0
+		}
+		sort.Float64s(f0)
+		median := f0[len(f0)/2]
+
+		sumSq := 0.0
+		for _, f := range f0 {
+			d := cents(f, median)
+			sumSq += d * d
+		}
+		spread += math.Sqrt(sumSq / float64(len(f0)))
+	}
+	spread /= float64(len(runs))
+
+	c.Value = spread
+	c.Score = percent((UnstableCents - spread) / (UnstableCents - StableCents))
+	return c
+}
+
+// glideRange scores the span of the widest glide, between its 5th and 95th
+// percentile pitch. With a target band the span must cover the band;
+// otherwise it is measured against DefaultGlideSemitones.
+func glideRange(runs [][]audio.PitchFrame, target Target) Criterion {
+	c := Criterion{Name: CriterionRange, Target: DefaultGlideSemitones, Unit: "semitones"}
+	if target.MinHz != nil && target.MaxHz != nil {
+		c.Target = cents(*target.MaxHz, *target.MinHz) / 100
+	}
+
+	bestLow, bestHigh := 0.0, 0.0
+	for _, run := range runs {
+		f0 := make([]float64, len(run))
+		for i, f := range run {
+			f0[i] = f.F0
+		}
+		sort.Float64s(f0)
+		low, high := f0[len(f0)*5/100], f0[len(f0)*95/100]
+		if bestLow == 0 || cents(high, low) > cents(bestHigh, bestLow) {
+			bestLow, bestHigh = low, high
+		}
+	}
+	if bestLow == 0 {
+		return c
+	}
+
+	c.Value = cents(bestHigh, bestLow) / 100
+	if target.MinHz != nil && target.MaxHz != nil {
+		// Only the part of the glide inside the band counts
+		low, high := math.Max(bestLow, *target.MinHz), math.Min(bestHigh, *target.MaxHz)
+		if high > low {
+			c.Score = percent(cents(high, low) / cents(*target.MaxHz, *target.MinHz))
+		}
+		return c
+	}
+	c.Score = percent(c.Value / DefaultGlideSemitones)
+	return c
+}
+
+// smoothness scores how few frame-to-frame steps of the glides jump by more
+// than GlideJumpCents. Value is the share of broken steps in percent.
+func smoothness(runs [][]audio.PitchFrame) Criterion {
+	c := Criterion{Name: CriterionSmoothness, Unit: "%"}
+
+	steps, jumps := 0, 0
+	for _, run := range runs {
+		for i := 1; i < len(run); i++ {
+			steps++
+			if math.Abs(cents(run[i].F0, run[i-1].F0)) > GlideJumpCents {
+				jumps++
+			}
+		}
+	}
+	if steps == 0 {
+		return c
+	}
+
+	fraction := float64(jumps) / float64(steps)
+	c.Value = 100 * fraction
+	c.Score = percent(1 - fraction/MaxGlideJumpFraction)
+	return c
+}
+
+// appendRepetitions adds a repetitions criterion when the exercise asks for
+// more than one. Attempts shorter than MinRepetitionFraction of goal do not count.
+func appendRepetitions(criteria []Criterion, attempts []float64, goal float64, repetitions int) []Criterion {
+	if repetitions <= 1 {
+		return criteria
+	}
+
+	done := 0
+	for _, a := range attempts {
+		if a >= goal*MinRepetitionFraction {
+			done++
+		}
+	}
+	return append(criteria, Criterion{
+		Name:   CriterionRepetitions,
+		Score:  percent(float64(done) / float64(repetitions)),
+		Value:  float64(done),
+		Target: float64(repetitions),
+		Unit:   "reps",
+	})
+}
@@ -0,0 +1,332 @@
+package scoring
+
+import (
+	"math"
+	"testing"
+	"voice-training-app/internal/audio"
+	"voice-training-app/internal/models"
+)
+
+const testHop = 0.01
+
+// track builds a contour at testHop from F0 values, 0 meaning unvoiced
+func track(f0 ...float64) *audio.PitchTrack {
+	t := &audio.PitchTrack{HopSeconds: testHop}
+	for i, f := range f0 {
+		t.Frames = append(t.Frames, audio.PitchFrame{Time: float64(i) * testHop, F0: f, Voiced: f > 0})
+		if f > 0 {
+			t.Summary.VoicedFrames++
+		}
+	}
+	t.Summary.TotalFrames = len(f0)
+	return t
+}
+
+// hold returns seconds of frames at hz
+func hold(hz, seconds float64) []float64 {
+	f0 := make([]float64, int(math.Round(seconds/testHop)))
+	for i := range f0 {
+		f0[i] = hz
+	}
+	return f0
+}
+
+// slide returns seconds of frames gliding exponentially from one pitch to another
+func slide(from, to, seconds float64) []float64 {
+	f0 := make([]float64, int(math.Round(seconds/testHop)))
+	for i := range f0 {
+		f0[i] = from * math.Pow(to/from, float64(i)/float64(len(f0)-1))
+	}
+	return f0
+}
+
+func concat(parts ...[]float64) []float64 {
+	var f0 []float64
+	for _, p := range parts {
+		f0 = append(f0, p...)
+	}
+	return f0
+}
+
+// activity builds voice activity from segment lengths; negative lengths are silence
+func activity(lengths ...float64) *audio.VADResult {
+	v := &audio.VADResult{}
+	t := 0.0
+	for _, l := range lengths {
+		seg := audio.Segment{Start: t, End: t + math.Abs(l), Kind: audio.SegmentUnvoiced}
+		if l < 0 {
+			seg.Kind = audio.SegmentSilence
+		} else {
+			v.ActiveSeconds += l
+		}
+		v.Segments = append(v.Segments, seg)
+		t = seg.End
+	}
+	return v
+}
+
+// formantsAt builds a formant track aligned with pitch, with the same F1 and
+// F2 on every voiced frame
+func formantsAt(pitch *audio.PitchTrack, f1, f2 float64) *audio.FormantTrack {
+	ft := &audio.FormantTrack{Frames: make([]audio.FormantFrame, len(pitch.Frames))}
+	for i, f := range pitch.Frames {
+		if f.Voiced {
+			ft.Frames[i] = audio.FormantFrame{Time: f.Time, F1: f1, F2: f2}
+		}
+	}
+	return ft
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+func TestScore(t *testing.T) {
+	band := func(kind string) Target {
+		return Target{Kind: kind, MinHz: ptr(180), MaxHz: ptr(220), Repetitions: 1}
+	}
+	withRepetitions := func(target Target, n int) Target {
+		target.Repetitions = n
+		return target
+	}
+	withFormants := func(target Target) Target {
+		target.F1MinHz, target.F1MaxHz = ptr(300), ptr(700)
+		return target
+	}
+
+	steady := track(hold(200, 5)...)
+	twoNotes := track(concat(hold(200, 5), hold(0, 0.5), hold(200, 2))...)
+	siren := track(slide(150, 300, 3)...)
+	broken := track(concat(hold(150, 1), hold(300, 1), hold(150, 1))...)
+	unvoiced := track(hold(0, 3)...)
+	empty := track()
+
+	tests := []struct {
+		name      string
+		target    Target
+		pitch     *audio.PitchTrack
+		formants  *audio.FormantTrack
+		vad       *audio.VADResult
+		criteria  map[string]int
+		wantScore int
+	}{
+		{
+			name:      "sustain held in band",
+			target:    band(models.ExerciseKindSustain),
+			pitch:     steady,
+			vad:       activity(5),
+			criteria:  map[string]int{CriterionInBand: 100, CriterionSustain: 100, CriterionStability: 100},
+			wantScore: 100,
+		},
+		{
+			name:      "sustain short and out of band",
+			target:    band(models.ExerciseKindSustain),
+			pitch:     track(hold(300, 2.5)...),
+			vad:       activity(2.5),
+			criteria:  map[string]int{CriterionInBand: 0, CriterionSustain: 50, CriterionStability: 100},
+			wantScore: 50,
+		},
+		{
+			name:   "sustain repetitions",
+			target: withRepetitions(Target{Kind: models.ExerciseKindSustain}, 3),
+			pitch:  twoNotes,
+			vad:    activity(5, -0.5, 2),
+			// Two of three notes; the second is 40% of the goal, too short to count as a repetition
+			criteria:  map[string]int{CriterionSustain: 70, CriterionStability: 100, CriterionRepetitions: 33},
+			wantScore: 68,
+		},
+		{
+			name:   "sustain resonance",
+			target: withFormants(Target{Kind: models.ExerciseKindSustain, Repetitions: 1}),
+			pitch:  steady,
+			// F1 above the band on every frame
+			formants:  formantsAt(steady, 800, 1500),
+			vad:       activity(5),
+			criteria:  map[string]int{CriterionSustain: 100, CriterionStability: 100, CriterionResonance: 0},
+			wantScore: 67,
+		},
+		{
+			name:   "sustain empty",
+			target: band(models.ExerciseKindSustain),
+			pitch:  empty,
+			vad:    activity(),
+			criteria: map[string]int{
+				CriterionInBand: 0, CriterionSustain: 0, CriterionStability: 0,
+			},
+		},
+		{
+			name:   "sustain unvoiced",
+			target: withRepetitions(band(models.ExerciseKindSustain), 2),
+			pitch:  unvoiced,
+			vad:    activity(3),
+			criteria: map[string]int{
+				CriterionInBand: 0, CriterionSustain: 0, CriterionStability: 0, CriterionRepetitions: 0,
+			},
+		},
+		{
+			// The 5th to 95th percentile of a one-octave siren spans 90% of it
+			name:      "glide without band",
+			target:    Target{Kind: models.ExerciseKindGlide, Repetitions: 1},
+			pitch:     siren,
+			vad:       activity(3),
+			criteria:  map[string]int{CriterionRange: 90, CriterionSmoothness: 100, CriterionDuration: 100},
+			wantScore: 97,
+		},
+		{
+			// Only the part of the siren inside 180-220 Hz counts against the band
+			name:      "glide with band",
+			target:    band(models.ExerciseKindGlide),
+			pitch:     siren,
+			vad:       activity(3),
+			criteria:  map[string]int{CriterionRange: 100, CriterionSmoothness: 100, CriterionDuration: 100},
+			wantScore: 100,
+		},
+		{
+			// Two octave jumps in 299 steps, against a MaxGlideJumpFraction of 10%
+			name:      "glide with breaks",
+			target:    Target{Kind: models.ExerciseKindGlide, Repetitions: 1},
+			pitch:     broken,
+			vad:       activity(3),
+			criteria:  map[string]int{CriterionRange: 100, CriterionSmoothness: 93, CriterionDuration: 100},
+			wantScore: 98,
+		},
+		{
+			name:     "glide empty",
+			target:   Target{Kind: models.ExerciseKindGlide, Repetitions: 1},
+			pitch:    empty,
+			vad:      activity(),
+			criteria: map[string]int{CriterionRange: 0, CriterionSmoothness: 0, CriterionDuration: 0},
+		},
+		{
+			name:     "glide unvoiced",
+			target:   band(models.ExerciseKindGlide),
+			pitch:    unvoiced,
+			vad:      activity(3),
+			criteria: map[string]int{CriterionRange: 0, CriterionSmoothness: 0, CriterionDuration: 0},
+		},
+		{
+			// Speaking time comes from voice activity, not from voiced frames
+			name:      "speech",
+			target:    band(models.ExerciseKindSpeech),
+			pitch:     track(concat(hold(200, 1), hold(0, 1), hold(300, 1))...),
+			vad:       activity(2, -1, 3),
+			criteria:  map[string]int{CriterionInBand: 50, CriterionDuration: 50},
+			wantScore: 50,
+		},
+		{
+			name:      "speech resonance",
+			target:    withFormants(band(models.ExerciseKindSpeech)),
+			pitch:     steady,
+			formants:  formantsAt(steady, 500, 1500),
+			vad:       activity(10),
+			criteria:  map[string]int{CriterionInBand: 100, CriterionResonance: 100, CriterionDuration: 100},
+			wantScore: 100,
+		},
+		{
+			name:     "speech empty",
+			target:   withFormants(band(models.ExerciseKindSpeech)),
+			pitch:    empty,
+			vad:      activity(),
+			criteria: map[string]int{CriterionInBand: 0, CriterionResonance: 0, CriterionDuration: 0},
+		},
+		{
+			name:      "speech unvoiced",
+			target:    band(models.ExerciseKindSpeech),
+			pitch:     unvoiced,
+			vad:       activity(3),
+			criteria:  map[string]int{CriterionInBand: 0, CriterionDuration: 30},
+			wantScore: 15,
+		},
+		{
+			name:      "breathing",
+			target:    Target{Kind: models.ExerciseKindBreathing, DurationSec: ptr(8), Repetitions: 1},
+			pitch:     unvoiced,
+			vad:       activity(-1, 8, -1),
+			criteria:  map[string]int{CriterionSustain: 100},
+			wantScore: 100,
+		},
+		{
+			// The 4 s breath is too short to count as a repetition
+			name:      "breathing repetitions",
+			target:    withRepetitions(Target{Kind: models.ExerciseKindBreathing}, 2),
+			pitch:     unvoiced,
+			vad:       activity(10, -2, 4),
+			criteria:  map[string]int{CriterionSustain: 70, CriterionRepetitions: 50},
+			wantScore: 60,
+		},
+		{
+			name:     "breathing empty",
+			target:   Target{Kind: models.ExerciseKindBreathing, Repetitions: 1},
+			pitch:    empty,
+			vad:      activity(),
+			criteria: map[string]int{CriterionSustain: 0},
+		},
+		{
+			// Hissing is unvoiced throughout and still scores
+			name:      "breathing unvoiced",
+			target:    Target{Kind: models.ExerciseKindBreathing, Repetitions: 1},
+			pitch:     unvoiced,
+			vad:       activity(3),
+			criteria:  map[string]int{CriterionSustain: 30},
+			wantScore: 30,
+		},
+		{
+			name:     "unknown kind",
+			target:   Target{Kind: "yodel", Repetitions: 1},
+			pitch:    steady,
+			vad:      activity(5),
+			criteria: map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Score(tt.target, tt.pitch, tt.formants, tt.vad)
+
+			got := map[string]int{}
+			for _, c := range result.Criteria {
+				got[c.Name] = c.Score
+			}
+			if len(got) != len(tt.criteria) {
+				t.Errorf("criteria = %v, want %v", got, tt.criteria)
+			}
+			for name, want := range tt.criteria {
+				if score, ok := got[name]; !ok || score != want {
+					t.Errorf("%s = %d (present %v), want %d", name, score, ok, want)
+				}
+			}
+			if result.Score != tt.wantScore {
+				t.Errorf("score = %d, want %d", result.Score, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestTargetFor(t *testing.T) {
+	profile := &models.VoiceProfile{
+		TargetPitchMinHz: ptr(170),
+		TargetPitchMaxHz: ptr(250),
+		TargetF1MinHz:    ptr(300),
+		TargetF1MaxHz:    ptr(700),
+	}
+
+	e := &models.Exercise{Kind: models.ExerciseKindSustain}
+	target := TargetFor(e, profile)
+	if target.MinHz == nil || *target.MinHz != 170 || *target.MaxHz != 250 {
+		t.Errorf("band = %v-%v, want the profile's 170-250", target.MinHz, target.MaxHz)
+	}
+	if target.F1MinHz == nil || target.F2MinHz != nil || target.Repetitions != 1 {
+		t.Errorf("target = %+v, want the profile's F1 band, no F2 band and 1 repetition", target)
+	}
+
+	e.TargetPitchMinHz, e.TargetPitchMaxHz, e.Repetitions = ptr(200), ptr(220), 3
+	target = TargetFor(e, profile)
+	if *target.MinHz != 200 || *target.MaxHz != 220 || target.Repetitions != 3 {
+		t.Errorf("target = %+v, want the exercise's 200-220 band and 3 repetitions", target)
+	}
+
+	target = TargetFor(&models.Exercise{Kind: models.ExerciseKindSpeech}, nil)
+	if target.MinHz != nil || target.hasFormantBand() {
+		t.Errorf("target without profile = %+v, want no bands", target)
+	}
+}
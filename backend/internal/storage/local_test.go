@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalMissingKeyIsNotFound(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, "recordings/missing.webm"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Stat(ctx, "recordings/missing.webm"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat = %v, want ErrNotFound", err)
+	}
+	if _, err := Fetch(ctx, store, "recordings/missing.webm", t.TempDir()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Fetch = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalFetchReturnsStoredFile(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "recordings/take.webm", strings.NewReader("audio"), -1, "audio/webm"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	localPath, err := Fetch(ctx, store, "recordings/take.webm", t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if want, _ := store.LocalPath("recordings/take.webm"); localPath != want {
+		t.Errorf("Fetch = %s, want the stored file %s", localPath, want)
+	}
+
+	obj, err := store.Get(ctx, "recordings/take.webm")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer obj.Close()
+	if data, err := io.ReadAll(obj); err != nil || string(data) != "audio" {
+		t.Errorf("Get read %q, %v, want audio", data, err)
+	}
+}
@@ -0,0 +1,400 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testBucket = "recordings"
+	testKeyID  = "AKIDEXAMPLE"
+	testSecret = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion = "eu-west-1"
+)
+
+// fakeS3 is an in-memory, path-style S3 endpoint that checks the SigV4
+// signature of every request against its own canonical request
+type fakeS3 struct {
+	t        *testing.T
+	pageSize int // Keys per ListObjectsV2 page
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	tokens  []string // Continuation tokens received by List, in order
+	ranges  []string // Range headers received by object GETs, in order
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3Store) {
+	t.Helper()
+	f := &fakeS3{t: t, pageSize: 1000, objects: map[string][]byte{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:        server.URL,
+		Region:          testRegion,
+		Bucket:          testBucket,
+		AccessKeyID:     testKeyID,
+		SecretAccessKey: testSecret,
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, store
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f.verify(r); err != nil {
+		f.t.Errorf("%s %s: %v", r.Method, r.URL, err)
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket)
+	if !ok {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(rest, "/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if key == "" {
+		f.list(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodHead, http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat))
+		w.Header().Set("ETag", `"etag"`)
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			return
+		}
+		f.get(w, r, data)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// get serves data, honouring a single bytes=start-end range
+func (f *fakeS3) get(w http.ResponseWriter, r *http.Request, data []byte) {
+	spec := r.Header.Get("Range")
+	f.ranges = append(f.ranges, spec)
+	if spec == "" {
+		w.Write(data)
+		return
+	}
+
+	var start, end int
+	if _, err := fmt.Sscanf(spec, "bytes=%d-%d", &start, &end); err != nil || start > end || start >= len(data) {
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	end = min(end, len(data)-1)
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(data[start : end+1])
+}
+
+// list serves ListObjectsV2 pages of pageSize keys. The continuation token is
+// the next key, prefixed so that it needs query escaping.
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("list-type") != "2" {
+		http.Error(w, "list-type must be 2", http.StatusBadRequest)
+		return
+	}
+
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, query.Get("prefix")) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	token := query.Get("continuation-token")
+	f.tokens = append(f.tokens, token)
+	if after, ok := strings.CutPrefix(token, "next=/"); ok {
+		keys = keys[sort.SearchStrings(keys, after):]
+	}
+
+	var result listBucketResult
+	for i, k := range keys {
+		if i == f.pageSize {
+			result.IsTruncated = true
+			result.NextContinuationToken = "next=/" + k
+			break
+		}
+		result.Contents = append(result.Contents, struct {
+			Key          string    `xml:"Key"`
+			LastModified time.Time `xml:"LastModified"`
+			ETag         string    `xml:"ETag"`
+			Size         int64     `xml:"Size"`
+		}{Key: k, Size: int64(len(f.objects[k]))})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// verify rebuilds the canonical request from what arrived on the wire and
+// checks the Authorization header against it
+func (f *fakeS3) verify(r *http.Request) error {
+	amzDate := r.Header.Get("X-Amz-Date")
+	now, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil {
+		return fmt.Errorf("bad X-Amz-Date %q", amzDate)
+	}
+	payload := r.Header.Get("X-Amz-Content-Sha256")
+	if payload != unsignedPayload {
+		return fmt.Errorf("X-Amz-Content-Sha256 = %q, want %q", payload, unsignedPayload)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		awsEscapePath(r.URL.Path),
+		awsCanonicalQuery(r.URL.Query()),
+		"host:" + r.Host + "\nx-amz-content-sha256:" + payload + "\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-content-sha256;x-amz-date",
+		payload,
+	}, "\n")
+
+	date := now.Format("20060102")
+	scope := date + "/" + testRegion + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + testSecret)
+	for _, part := range []string{date, testRegion, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	want := fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s",
+		testKeyID, scope, hex.EncodeToString(hmacSHA256(key, stringToSign)))
+
+	if got := r.Header.Get("Authorization"); got != want {
+		return fmt.Errorf("Authorization = %q\nwant %q\ncanonical request:\n%s", got, want, canonicalRequest)
+	}
+	return nil
+}
+
+// awsEscape encodes s as SigV4 requires, independently of uriEncode: query
+// escaping with spaces as %20 and ~ left alone
+func awsEscape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(url.QueryEscape(s), "+", "%20"), "%7E", "~")
+}
+
+func awsEscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = awsEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+func awsCanonicalQuery(query url.Values) string {
+	var parts []string
+	for k, values := range query {
+		for _, v := range values {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "&")
+}
+
+func TestS3SignsRequests(t *testing.T) {
+	f, store := newFakeS3(t)
+	ctx := context.Background()
+
+	// Spaces, plus signs and tildes all escape differently in paths and queries
+	key := "user 1/take+2~final (1).webm"
+	if err := store.Put(ctx, key, strings.NewReader("audio"), -1, "audio/webm"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, ok := f.objects[key]; !ok {
+		t.Fatalf("object stored under %v, want %q", f.objects, key)
+	}
+
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size != 5 || info.ETag != `"etag"` {
+		t.Errorf("Stat = %+v, want size 5 and the server's ETag", info)
+	}
+
+	if _, err := store.List(ctx, "user 1/take+"); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3SignatureUsesSecret(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:        server.URL,
+		Region:          testRegion,
+		Bucket:          testBucket,
+		AccessKeyID:     testKeyID,
+		SecretAccessKey: "not-the-secret",
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(context.Background(), "a.webm"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	f := &fakeS3{t: t}
+	if err := f.verify(got); err == nil {
+		t.Fatal("request signed with the wrong secret verified")
+	}
+}
+
+func TestS3ListFollowsContinuationTokens(t *testing.T) {
+	f, store := newFakeS3(t)
+	f.pageSize = 2
+	for _, k := range []string{"u1/a", "u1/b", "u1/c", "u1/d", "u1/e", "u2/a"} {
+		f.objects[k] = []byte(k)
+	}
+
+	objects, err := store.List(context.Background(), "u1/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	var keys []string
+	for _, o := range objects {
+		keys = append(keys, o.Key)
+		if o.Size != 4 {
+			t.Errorf("%s size = %d, want 4", o.Key, o.Size)
+		}
+	}
+	if want := "u1/a u1/b u1/c u1/d u1/e"; strings.Join(keys, " ") != want {
+		t.Errorf("keys = %v, want %s", keys, want)
+	}
+	if want := []string{"", "next=/u1/c", "next=/u1/e"}; strings.Join(f.tokens, ",") != strings.Join(want, ",") {
+		t.Errorf("continuation tokens = %q, want %q", f.tokens, want)
+	}
+}
+
+func TestS3ListSinglePage(t *testing.T) {
+	f, store := newFakeS3(t)
+	f.pageSize = 2
+	f.objects["u1/a"] = []byte("a")
+
+	objects, err := store.List(context.Background(), "u1/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != 1 || len(f.tokens) != 1 {
+		t.Errorf("got %d objects in %d requests, want 1 in 1", len(objects), len(f.tokens))
+	}
+
+	objects, err = store.List(context.Background(), "none/")
+	if err != nil || objects == nil || len(objects) != 0 {
+		t.Errorf("List of empty prefix = %v, %v, want an empty slice", objects, err)
+	}
+}
+
+func TestS3GetReadsRanges(t *testing.T) {
+	f, store := newFakeS3(t)
+	f.objects["take.wav"] = []byte("0123456789")
+
+	obj, err := store.Get(context.Background(), "take.wav")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer obj.Close()
+	if len(f.ranges) != 0 {
+		t.Fatalf("Get fetched the body before the first Read: %q", f.ranges)
+	}
+
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(obj, buf); err != nil || string(buf) != "012" {
+		t.Fatalf("first read = %q, %v, want 012", buf, err)
+	}
+	// Consecutive reads continue the open response
+	if _, err := io.ReadFull(obj, buf); err != nil || string(buf) != "345" {
+		t.Fatalf("second read = %q, %v, want 345", buf, err)
+	}
+
+	if _, err := obj.Seek(-3, io.SeekEnd); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	rest, err := io.ReadAll(obj)
+	if err != nil || string(rest) != "789" {
+		t.Fatalf("read after seek = %q, %v, want 789", rest, err)
+	}
+
+	// Seeking to the current position keeps the response; reading at the end
+	// does not request anything
+	if _, err := obj.Seek(0, io.SeekCurrent); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if n, err := obj.Read(buf); n != 0 || err != io.EOF {
+		t.Fatalf("read at end = %d, %v, want 0, EOF", n, err)
+	}
+
+	if want := []string{"bytes=0-9", "bytes=7-9"}; strings.Join(f.ranges, ",") != strings.Join(want, ",") {
+		t.Errorf("ranges = %q, want %q", f.ranges, want)
+	}
+}
+
+func TestS3GetDetectsTruncatedBody(t *testing.T) {
+	f, store := newFakeS3(t)
+	f.objects["take.wav"] = []byte("0123456789")
+
+	obj, err := store.Get(context.Background(), "take.wav")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer obj.Close()
+
+	// The object shrinks between the HEAD and the ranged GET
+	f.objects["take.wav"] = []byte("01234")
+	data, err := io.ReadAll(obj)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || !bytes.Equal(data, []byte("01234")) {
+		t.Errorf("ReadAll = %q, %v, want 01234 and io.ErrUnexpectedEOF", data, err)
+	}
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: object not found")
+	ErrInvalidKey = errors.New("storage: invalid object key")
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+	ETag        string
+}
+
+// Object is an open stored object. Seeking is supported so objects can be
+// served with byte ranges.
+type Object interface {
+	io.ReadSeekCloser
+	Info() ObjectInfo
+}
+
+// Store is a flat blob store addressed by slash-separated keys
+type Store interface {
+	// Put stores size bytes from r under key, replacing any existing object.
+	// A negative size means the length is unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key
+	Get(ctx context.Context, key string) (Object, error)
+	// Stat returns the metadata of the object stored under key
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete removes the object stored under key. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose keys start with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// LocalPather is implemented by stores that keep objects as local files, so
+// callers that need a file path can skip the download
+type LocalPather interface {
+	LocalPath(key string) (string, error)
+}
+
+// Default is the store used by the application, set by Init
+var Default Store
+
+// Init configures Default from the environment. STORAGE_BACKEND selects
+// "local" (the default) or "s3".
+func Init() error {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		root := os.Getenv("STORAGE_LOCAL_ROOT")
+		if root == "" {
+			root = "uploads"
+		}
+		store, err := NewLocalStore(root)
+		if err != nil {
+			return err
+		}
+		Default = store
+	case "s3":
+		store, err := NewS3StoreFromEnv()
+		if err != nil {
+			return err
+		}
+		Default = store
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+
+	return nil
+}
+
+// Fetch makes the object under key available as a local file inside dir.
+// Local stores return the stored file itself; other stores download a copy.
+// A missing object is reported as ErrNotFound either way.
+func Fetch(ctx context.Context, store Store, key, dir string) (string, error) {
+	if local, ok := store.(LocalPather); ok {
+		localPath, err := local.LocalPath(key)
+		if err != nil {
+			return "", err
+		}
+		_, err = os.Stat(localPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", ErrNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		return localPath, nil
+	}
+
+	obj, err := store.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer obj.Close()
+
+	localPath := filepath.Join(dir, path.Base(key))
+	file, err := os.Create(localPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create local copy: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, obj); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", key, err)
+	}
+
+	return localPath, nil
+}
+
+// PutFile uploads a local file under key
+func PutFile(ctx context.Context, store Store, key, localPath, contentType string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	return store.Put(ctx, key, file, info.Size(), contentType)
+}
+
+// cleanKey validates a key and normalizes it to a relative slash path
+func cleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+
+	cleaned := path.Clean(key)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidKey
+	}
+
+	return cleaned, nil
+}
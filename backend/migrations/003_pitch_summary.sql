@@ -0,0 +1,9 @@
+-- Add pitch contour summary statistics to recordings
+-- pitch_hz keeps holding the median F0 of the voiced frames
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS pitch_mean_hz FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS pitch_min_hz FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS pitch_max_hz FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS pitch_p10_hz FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS pitch_p90_hz FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS pitch_stddev_hz FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS voiced_ratio FLOAT;
@@ -0,0 +1,13 @@
+-- Create pitch_frames table holding the full F0 contour of each processed recording
+CREATE TABLE IF NOT EXISTS pitch_frames (
+  recording_id UUID NOT NULL REFERENCES recordings(id) ON DELETE CASCADE,
+  frame_index INT NOT NULL,
+  time_sec FLOAT NOT NULL,
+  f0_hz FLOAT, -- NULL when the frame is unvoiced
+  confidence FLOAT NOT NULL DEFAULT 0,
+  voiced BOOLEAN NOT NULL DEFAULT FALSE,
+  PRIMARY KEY (recording_id, frame_index)
+);
+
+-- Create index for time-range queries over a contour
+CREATE INDEX IF NOT EXISTS idx_pitch_frames_recording_time ON pitch_frames(recording_id, time_sec);
@@ -0,0 +1,24 @@
+-- Create jobs table backing the background job queue
+CREATE TABLE IF NOT EXISTS jobs (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  kind VARCHAR(100) NOT NULL,
+  payload JSONB NOT NULL DEFAULT '{}',
+  dedupe_key VARCHAR(255),
+  status VARCHAR(20) NOT NULL DEFAULT 'queued', -- queued, running, done, dead
+  attempts INT NOT NULL DEFAULT 0,
+  max_attempts INT NOT NULL DEFAULT 5,
+  run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  locked_at TIMESTAMP,
+  last_error TEXT,
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Create index for workers claiming the next runnable job
+CREATE INDEX IF NOT EXISTS idx_jobs_runnable ON jobs(run_at) WHERE status = 'queued';
+
+-- Only one live job per dedupe key
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_dedupe_live ON jobs(dedupe_key)
+  WHERE dedupe_key IS NOT NULL AND status IN ('queued', 'running');
+
+CREATE INDEX IF NOT EXISTS idx_jobs_dedupe_key ON jobs(dedupe_key);
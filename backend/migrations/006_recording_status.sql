@@ -0,0 +1,12 @@
+-- Add processing status lifecycle to recordings
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending'
+  CHECK (status IN ('pending', 'processing', 'done', 'failed'));
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS failure_reason TEXT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS processed_at TIMESTAMP;
+
+-- Recordings analyzed before this migration are done
+UPDATE recordings SET status = 'done', processed_at = updated_at
+WHERE status = 'pending' AND (voiced_ratio IS NOT NULL OR pitch_hz IS NOT NULL);
+
+-- Create index for status filtering
+CREATE INDEX IF NOT EXISTS idx_recordings_user_status ON recordings(user_id, status);
@@ -0,0 +1,13 @@
+-- Add speaking time and voice activity segments to recordings
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS active_seconds FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS speech_start_sec FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS speech_end_sec FLOAT;
+
+CREATE TABLE IF NOT EXISTS recording_segments (
+  recording_id UUID NOT NULL REFERENCES recordings(id) ON DELETE CASCADE,
+  segment_index INT NOT NULL,
+  start_sec FLOAT NOT NULL,
+  end_sec FLOAT NOT NULL,
+  kind VARCHAR(20) NOT NULL CHECK (kind IN ('voiced', 'unvoiced', 'silence')),
+  PRIMARY KEY (recording_id, segment_index)
+);
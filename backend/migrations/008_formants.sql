@@ -0,0 +1,9 @@
+-- Add formant (F1-F3) estimates to pitch frames and recordings
+ALTER TABLE pitch_frames ADD COLUMN IF NOT EXISTS f1_hz FLOAT;
+ALTER TABLE pitch_frames ADD COLUMN IF NOT EXISTS f2_hz FLOAT;
+ALTER TABLE pitch_frames ADD COLUMN IF NOT EXISTS f3_hz FLOAT;
+
+-- Per-recording medians over voiced frames
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS f1_hz FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS f2_hz FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS f3_hz FLOAT;
@@ -0,0 +1,7 @@
+-- Add voice quality measures to recordings
+-- Jitter and shimmer are stored as percentages, HNR in dB
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS jitter_local_pct FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS jitter_rap_pct FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS shimmer_local_pct FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS shimmer_apq11_pct FLOAT;
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS hnr_db FLOAT;
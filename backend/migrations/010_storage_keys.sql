@@ -0,0 +1,5 @@
+-- recordings.file_path now holds a storage key relative to the blob store
+-- root instead of a path relative to the server's working directory
+UPDATE recordings
+SET file_path = substr(file_path, length('uploads/') + 1)
+WHERE file_path LIKE 'uploads/%';
@@ -0,0 +1,21 @@
+-- Create upload_sessions table tracking resumable (tus-style) uploads
+-- Received chunks are kept in blob storage until the upload completes
+CREATE TABLE IF NOT EXISTS upload_sessions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  filename VARCHAR(255) NOT NULL,
+  content_type VARCHAR(255) NOT NULL DEFAULT '',
+  upload_length BIGINT NOT NULL CHECK (upload_length > 0),
+  upload_offset BIGINT NOT NULL DEFAULT 0,
+  recording_id UUID REFERENCES recordings(id) ON DELETE SET NULL,
+  completed_at TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Create index on user_id for ownership lookups
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_user_id ON upload_sessions(user_id);
+
+-- Create index for reaping abandoned uploads
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires_at ON upload_sessions(expires_at);
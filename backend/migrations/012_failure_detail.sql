@@ -0,0 +1,2 @@
+-- Keep the tool output (e.g. ffmpeg stderr) behind a processing failure
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS failure_detail TEXT;
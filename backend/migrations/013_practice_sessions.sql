@@ -0,0 +1,26 @@
+-- Track the lifecycle of practice sessions
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active'
+  CHECK (status IN ('active', 'completed'));
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS active_seconds FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS last_heartbeat_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT NOW();
+
+-- Sessions written before this migration have no lifecycle to resume
+UPDATE sessions SET status = 'completed', completed_at = created_at
+WHERE completed_at IS NULL AND duration IS NOT NULL;
+
+UPDATE sessions SET exercises_completed = 0 WHERE exercises_completed IS NULL;
+UPDATE sessions SET xp_earned = 0 WHERE xp_earned IS NULL;
+ALTER TABLE sessions ALTER COLUMN exercises_completed SET NOT NULL;
+ALTER TABLE sessions ALTER COLUMN xp_earned SET NOT NULL;
+
+-- Create index for finding a user's open session
+CREATE INDEX IF NOT EXISTS idx_sessions_user_status ON sessions(user_id, status);
+
+-- Link recordings to the session they were made in
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS session_id UUID REFERENCES sessions(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_recordings_session_id ON recordings(session_id);
+
+-- Resumable uploads remember the session until the recording is created
+ALTER TABLE upload_sessions ADD COLUMN IF NOT EXISTS session_id UUID REFERENCES sessions(id) ON DELETE SET NULL;
@@ -0,0 +1,20 @@
+-- Streaks are counted in the user's own calendar days
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+-- Each token covers one missed day without breaking the streak
+ALTER TABLE users ADD COLUMN IF NOT EXISTS streak_freezes INT NOT NULL DEFAULT 0;
+
+-- Create xp_events table; one row per event that earned XP
+CREATE TABLE IF NOT EXISTS xp_events (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  source VARCHAR(40) NOT NULL,
+  source_id TEXT NOT NULL,
+  xp INT NOT NULL CHECK (xp >= 0),
+  occurred_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP DEFAULT NOW(),
+  -- An event earns XP at most once
+  UNIQUE (source, source_id)
+);
+
+-- Create index for a user's XP history
+CREATE INDEX IF NOT EXISTS idx_xp_events_user_occurred ON xp_events(user_id, occurred_at DESC);
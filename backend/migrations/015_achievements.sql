@@ -0,0 +1,11 @@
+-- Target pitch band of a user; median pitch inside it counts as a hit
+ALTER TABLE users ADD COLUMN IF NOT EXISTS target_pitch_min_hz FLOAT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS target_pitch_max_hz FLOAT;
+
+-- Create user_achievements table; the catalog itself lives in code
+CREATE TABLE IF NOT EXISTS user_achievements (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  achievement_id VARCHAR(64) NOT NULL,
+  unlocked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, achievement_id)
+);
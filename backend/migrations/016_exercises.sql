@@ -0,0 +1,74 @@
+-- Admins manage the exercise library; grant with UPDATE users SET is_admin = TRUE
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Create exercise_categories table
+CREATE TABLE IF NOT EXISTS exercise_categories (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  slug VARCHAR(50) UNIQUE NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  sort_order INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Create exercises table with the targets a recording is measured against
+CREATE TABLE IF NOT EXISTS exercises (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  category_id UUID NOT NULL REFERENCES exercise_categories(id) ON DELETE RESTRICT,
+  slug VARCHAR(80) UNIQUE NOT NULL,
+  title VARCHAR(200) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  instructions TEXT NOT NULL DEFAULT '',
+  kind VARCHAR(20) NOT NULL CHECK (kind IN ('sustain', 'glide', 'speech', 'breathing')),
+  difficulty INT NOT NULL CHECK (difficulty BETWEEN 1 AND 5),
+  target_pitch_min_hz FLOAT,
+  target_pitch_max_hz FLOAT,
+  target_duration_sec FLOAT,
+  repetitions INT NOT NULL DEFAULT 1 CHECK (repetitions > 0),
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW(),
+  CHECK (target_pitch_min_hz IS NULL OR target_pitch_max_hz > target_pitch_min_hz)
+);
+
+-- Create index for browsing by category
+CREATE INDEX IF NOT EXISTS idx_exercises_category_difficulty ON exercises(category_id, difficulty);
+
+-- Link recordings to the exercise they were made for
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS exercise_id UUID REFERENCES exercises(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_recordings_exercise_id ON recordings(exercise_id);
+ALTER TABLE upload_sessions ADD COLUMN IF NOT EXISTS exercise_id UUID REFERENCES exercises(id) ON DELETE SET NULL;
+
+-- Starter library
+INSERT INTO exercise_categories (slug, name, description, sort_order) VALUES
+  ('breathing', 'Breathing', 'Breath support and control for steady phonation', 1),
+  ('resonance', 'Resonance', 'Shaping where the voice resonates', 2),
+  ('pitch-control', 'Pitch Control', 'Finding, holding and moving between pitches', 3)
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO exercises (category_id, slug, title, description, instructions, kind, difficulty,
+                       target_pitch_min_hz, target_pitch_max_hz, target_duration_sec, repetitions)
+SELECT c.id, e.slug, e.title, e.description, e.instructions, e.kind, e.difficulty,
+       e.min_hz, e.max_hz, e.duration, e.repetitions
+FROM (VALUES
+  ('breathing', 'sustained-hiss', 'Sustained Hiss', 'Build steady airflow',
+   'Breathe in low, then release a steady "sss" for as long as you comfortably can.',
+   'breathing', 1, NULL::float, NULL::float, 15.0::float, 3),
+  ('breathing', 'lip-trill', 'Lip Trill', 'Balance air pressure and vocal fold closure',
+   'Let your lips buzz on a relaxed "brr" while humming at a comfortable pitch.',
+   'sustain', 1, NULL, NULL, 8.0, 3),
+  ('resonance', 'humming-mm', 'Forward Hum', 'Feel resonance move forward into the face',
+   'Hum on "mm" with relaxed jaw and feel the buzz on your lips and nose.',
+   'sustain', 2, NULL, NULL, 10.0, 3),
+  ('resonance', 'reading-passage', 'Rainbow Passage', 'Carry resonance into connected speech',
+   'Read the first paragraph of the Rainbow Passage at an easy pace.',
+   'speech', 2, NULL, NULL, 30.0, 1),
+  ('pitch-control', 'held-note', 'Held Note', 'Hold one pitch steadily',
+   'Pick a comfortable note and hold it on "ah" without letting it waver.',
+   'sustain', 2, NULL, NULL, 5.0, 3),
+  ('pitch-control', 'siren', 'Siren', 'Glide smoothly through your range',
+   'Slide from your lowest comfortable note to your highest and back on "oo".',
+   'glide', 3, NULL, NULL, 6.0, 3)
+) AS e(category, slug, title, description, instructions, kind, difficulty, min_hz, max_hz, duration, repetitions)
+JOIN exercise_categories c ON c.slug = e.category
+ON CONFLICT (slug) DO NOTHING;
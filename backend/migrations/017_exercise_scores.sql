@@ -0,0 +1,4 @@
+-- Score of a recording against the exercise it was made for, with the
+-- per-criterion breakdown it was computed from
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS exercise_score INT CHECK (exercise_score BETWEEN 0 AND 100);
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS score_breakdown JSONB;
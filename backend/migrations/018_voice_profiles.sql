@@ -0,0 +1,35 @@
+-- Create voice_profiles table: a user's baseline range, goal and target bands
+CREATE TABLE IF NOT EXISTS voice_profiles (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  goal VARCHAR(20) CHECK (goal IN ('deepen', 'raise', 'range_extend', 'resonance')),
+  baseline_min_hz FLOAT, -- 5th percentile of voiced pitch over the baseline recordings
+  baseline_median_hz FLOAT,
+  baseline_max_hz FLOAT, -- 95th percentile
+  baseline_f1_hz FLOAT,
+  baseline_f2_hz FLOAT,
+  baseline_recording_ids UUID[] NOT NULL DEFAULT '{}',
+  baseline_measured_at TIMESTAMP,
+  target_pitch_min_hz FLOAT,
+  target_pitch_max_hz FLOAT,
+  target_f1_min_hz FLOAT,
+  target_f1_max_hz FLOAT,
+  target_f2_min_hz FLOAT,
+  target_f2_max_hz FLOAT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Move the target pitch band off users into the profile
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM information_schema.columns
+             WHERE table_name = 'users' AND column_name = 'target_pitch_min_hz') THEN
+    INSERT INTO voice_profiles (user_id, target_pitch_min_hz, target_pitch_max_hz)
+    SELECT id, target_pitch_min_hz, target_pitch_max_hz FROM users
+    WHERE target_pitch_min_hz IS NOT NULL AND target_pitch_max_hz IS NOT NULL
+    ON CONFLICT (user_id) DO NOTHING;
+  END IF;
+END $$;
+
+ALTER TABLE users DROP COLUMN IF EXISTS target_pitch_min_hz;
+ALTER TABLE users DROP COLUMN IF EXISTS target_pitch_max_hz;
@@ -0,0 +1,2 @@
+-- Keyset pagination of a user's recordings on (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_recordings_user_created_id ON recordings(user_id, created_at DESC, id DESC);
@@ -0,0 +1,33 @@
+-- Let users label recordings with a title and notes
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS title VARCHAR(200);
+ALTER TABLE recordings ADD COLUMN IF NOT EXISTS notes TEXT;
+
+-- Create tags table; names are stored lowercased and are unique per user
+CREATE TABLE IF NOT EXISTS tags (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(50) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS recording_tags (
+  recording_id UUID NOT NULL REFERENCES recordings(id) ON DELETE CASCADE,
+  tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+  PRIMARY KEY (recording_id, tag_id)
+);
+
+-- Create index for finding the recordings with a tag
+CREATE INDEX IF NOT EXISTS idx_recording_tags_tag_id ON recording_tags(tag_id);
+
+-- Keep updated_at current on every change to a recording
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+  NEW.updated_at = NOW();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS recordings_set_updated_at ON recordings;
+CREATE TRIGGER recordings_set_updated_at BEFORE UPDATE ON recordings
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();